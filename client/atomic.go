@@ -0,0 +1,267 @@
+package client
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/dyaksa/barbarian"
+)
+
+type counterStripe struct {
+	requests  atomic.Uint64
+	successes atomic.Uint64
+	failures  atomic.Uint64
+	_         [40]byte
+}
+
+type atomicGeneration struct {
+	id     uint64
+	state  State
+	expiry time.Time
+
+	stripes []counterStripe
+	mask    uint32
+
+	halfOpenRequests       atomic.Uint64
+	consecutiveFailures    atomic.Uint64
+	successesAtLastFailure atomic.Uint64
+}
+
+func (g *atomicGeneration) stripe() *counterStripe {
+	return &g.stripes[rand.Uint32()&g.mask]
+}
+
+func (g *atomicGeneration) counts() Counts {
+	var counts Counts
+	for i := range g.stripes {
+		counts.Requests += g.stripes[i].requests.Load()
+		counts.TotalSuccesses += g.stripes[i].successes.Load()
+		counts.TotalFailures += g.stripes[i].failures.Load()
+	}
+
+	counts.ConsecutiveFailures = g.consecutiveFailures.Load()
+	if counts.ConsecutiveFailures == 0 {
+		counts.ConsecutiveSuccesses = counts.TotalSuccesses - min(counts.TotalSuccesses, g.successesAtLastFailure.Load())
+	}
+	return counts
+}
+
+type AtomicCircuitBreaker struct {
+	name          string
+	maxRequests   uint32
+	interval      time.Duration
+	timeout       time.Duration
+	readyToTrip   func(counts Counts) bool
+	isSuccessful  func(err error) bool
+	onStateChange func(name string, from State, to State)
+
+	stripes    int
+	generation atomic.Pointer[atomicGeneration]
+}
+
+var _ barbarian.CircuitBreaker = (*AtomicCircuitBreaker)(nil)
+
+func NewAtomicCircuitBreaker(st Settings) *AtomicCircuitBreaker {
+	cb := new(AtomicCircuitBreaker)
+
+	cb.name = st.Name
+	cb.onStateChange = st.OnStateChange
+
+	if st.MaxRequests == 0 {
+		cb.maxRequests = 1
+	} else {
+		cb.maxRequests = st.MaxRequests
+	}
+
+	if st.Interval <= 0 {
+		cb.interval = defaultInterval
+	} else {
+		cb.interval = st.Interval
+	}
+
+	if st.Timeout <= 0 {
+		cb.timeout = defaultTimeout
+	} else {
+		cb.timeout = st.Timeout
+	}
+
+	if st.ReadyToTrip == nil {
+		cb.readyToTrip = defaultReadyToTrip
+	} else {
+		cb.readyToTrip = st.ReadyToTrip
+	}
+
+	if st.IsSuccessful == nil {
+		cb.isSuccessful = defaultIsSuccessful
+	} else {
+		cb.isSuccessful = st.IsSuccessful
+	}
+
+	cb.stripes = 1 << bits.Len(uint(runtime.GOMAXPROCS(0)-1))
+	cb.generation.Store(cb.newGeneration(0, StateClosed, time.Now()))
+
+	return cb
+}
+
+func (cb *AtomicCircuitBreaker) Name() string {
+	return cb.name
+}
+
+func (cb *AtomicCircuitBreaker) State() State {
+	return cb.current(time.Now()).state
+}
+
+func (cb *AtomicCircuitBreaker) Counts() Counts {
+	return cb.generation.Load().counts()
+}
+
+func (cb *AtomicCircuitBreaker) IsCircuitBreakerOpen() bool {
+	return cb.State() == StateOpen
+}
+
+func (cb *AtomicCircuitBreaker) Execute(req func() (interface{}, error)) (interface{}, error) {
+	return cb.execute(req, nil)
+}
+
+func (cb *AtomicCircuitBreaker) execute(req func() (interface{}, error), ignore func(err error) bool) (interface{}, error) {
+	g, err := cb.beforeRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		e := recover()
+		if e != nil {
+			cb.afterRequest(g, false, false)
+			panic(e)
+		}
+	}()
+
+	result, err := req()
+	err, ignored := unwrapIgnored(err)
+	cb.afterRequest(g, cb.isSuccessful(err), ignored || (ignore != nil && ignore(err)))
+	return result, err
+}
+
+func (cb *AtomicCircuitBreaker) beforeRequest() (*atomicGeneration, error) {
+	g := cb.current(time.Now())
+
+	switch g.state {
+	case StateOpen:
+		return nil, ErrOpenState
+	case StateHalfOpen:
+		for {
+			requests := g.halfOpenRequests.Load()
+			if requests >= uint64(cb.maxRequests) {
+				return nil, ErrTooManyRequests
+			}
+			if g.halfOpenRequests.CompareAndSwap(requests, requests+1) {
+				break
+			}
+		}
+	}
+
+	g.stripe().requests.Add(1)
+	return g, nil
+}
+
+func (cb *AtomicCircuitBreaker) afterRequest(g *atomicGeneration, success bool, ignored bool) {
+	now := time.Now()
+	if cb.current(now) != g {
+		return
+	}
+
+	if ignored {
+		g.stripe().requests.Add(^uint64(0))
+		if g.state == StateHalfOpen {
+			g.halfOpenRequests.Add(^uint64(0))
+		}
+		return
+	}
+
+	if success {
+		cb.onSuccess(g, now)
+	} else {
+		cb.onFailure(g, now)
+	}
+}
+
+func (cb *AtomicCircuitBreaker) onSuccess(g *atomicGeneration, now time.Time) {
+	g.stripe().successes.Add(1)
+	if g.consecutiveFailures.Load() != 0 {
+		g.consecutiveFailures.Store(0)
+	}
+
+	if g.state == StateHalfOpen && g.counts().ConsecutiveSuccesses >= uint64(cb.maxRequests) {
+		cb.advance(g, StateClosed, now)
+	}
+}
+
+func (cb *AtomicCircuitBreaker) onFailure(g *atomicGeneration, now time.Time) {
+	g.stripe().failures.Add(1)
+
+	switch g.state {
+	case StateClosed:
+		g.consecutiveFailures.Add(1)
+		counts := g.counts()
+		g.successesAtLastFailure.Store(counts.TotalSuccesses)
+		if cb.readyToTrip(counts) {
+			cb.advance(g, StateOpen, now)
+		}
+	case StateHalfOpen:
+		cb.advance(g, StateOpen, now)
+	}
+}
+
+func (cb *AtomicCircuitBreaker) current(now time.Time) *atomicGeneration {
+	for {
+		g := cb.generation.Load()
+
+		switch g.state {
+		case StateClosed:
+			if !g.expiry.IsZero() && g.expiry.Before(now) {
+				cb.advance(g, StateClosed, now)
+				continue
+			}
+		case StateOpen:
+			if g.expiry.Before(now) {
+				cb.advance(g, StateHalfOpen, now)
+				continue
+			}
+		}
+		return g
+	}
+}
+
+func (cb *AtomicCircuitBreaker) advance(g *atomicGeneration, state State, now time.Time) {
+	next := cb.newGeneration(g.id+1, state, now)
+	if !cb.generation.CompareAndSwap(g, next) {
+		return
+	}
+
+	if g.state != state && cb.onStateChange != nil {
+		cb.onStateChange(cb.name, g.state, state)
+	}
+}
+
+func (cb *AtomicCircuitBreaker) newGeneration(id uint64, state State, now time.Time) *atomicGeneration {
+	g := &atomicGeneration{
+		id:      id,
+		state:   state,
+		stripes: make([]counterStripe, cb.stripes),
+		mask:    uint32(cb.stripes - 1),
+	}
+
+	switch state {
+	case StateClosed:
+		if cb.interval > 0 {
+			g.expiry = now.Add(cb.interval)
+		}
+	case StateOpen:
+		g.expiry = now.Add(cb.timeout)
+	}
+	return g
+}
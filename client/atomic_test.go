@@ -0,0 +1,149 @@
+package client
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dyaksa/barbarian"
+)
+
+func execute(cb barbarian.CircuitBreaker, err error) error {
+	_, err = cb.Execute(func() (interface{}, error) { return nil, err })
+	return err
+}
+
+func TestAtomicCircuitBreakerStateMachine(t *testing.T) {
+	var mutex sync.Mutex
+	var transitions []State
+	cb := NewAtomicCircuitBreaker(Settings{
+		Name:        "atomic",
+		MaxRequests: 2,
+		Timeout:     20 * time.Millisecond,
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 3 },
+		OnStateChange: func(name string, from, to State) {
+			mutex.Lock()
+			transitions = append(transitions, to)
+			mutex.Unlock()
+		},
+	})
+
+	execute(cb, nil)
+	for i := 0; i < 3; i++ {
+		execute(cb, errTest)
+	}
+	if state := cb.State(); state != StateOpen {
+		t.Fatalf("expected open, got %s", state)
+	}
+	if err := execute(cb, nil); err != ErrOpenState {
+		t.Fatalf("expected %v, got %v", ErrOpenState, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if state := cb.State(); state != StateHalfOpen {
+		t.Fatalf("expected half-open, got %s", state)
+	}
+
+	done := make(chan struct{})
+	go cb.Execute(func() (interface{}, error) {
+		<-done
+		return nil, nil
+	})
+	for cb.Counts().Requests != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	execute(cb, nil)
+	if err := execute(cb, nil); err != ErrTooManyRequests {
+		t.Fatalf("expected %v, got %v", ErrTooManyRequests, err)
+	}
+	close(done)
+
+	for cb.State() == StateHalfOpen {
+		time.Sleep(time.Millisecond)
+	}
+	if state := cb.State(); state != StateClosed {
+		t.Fatalf("expected closed, got %s", state)
+	}
+
+	want := []State{StateOpen, StateHalfOpen, StateClosed}
+	time.Sleep(10 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(transitions) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("expected transitions %v, got %v", want, transitions)
+		}
+	}
+}
+
+func TestAtomicCircuitBreakerCounts(t *testing.T) {
+	cb := NewAtomicCircuitBreaker(Settings{})
+
+	execute(cb, errTest)
+	execute(cb, nil)
+	execute(cb, nil)
+	execute(cb, Ignore(errTest))
+
+	counts := cb.Counts()
+	if counts.Requests != 3 || counts.TotalSuccesses != 2 || counts.TotalFailures != 1 {
+		t.Fatalf("unexpected counts %+v", counts)
+	}
+	if counts.ConsecutiveSuccesses != 2 || counts.ConsecutiveFailures != 0 {
+		t.Fatalf("unexpected consecutive counts %+v", counts)
+	}
+}
+
+func TestAtomicCircuitBreakerConcurrentTrip(t *testing.T) {
+	var mutex sync.Mutex
+	var opened int
+	cb := NewAtomicCircuitBreaker(Settings{
+		Timeout:     time.Hour,
+		ReadyToTrip: func(counts Counts) bool { return counts.TotalFailures >= 100 },
+		OnStateChange: func(name string, from, to State) {
+			mutex.Lock()
+			opened++
+			mutex.Unlock()
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				execute(cb, errTest)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if state := cb.State(); state != StateOpen {
+		t.Fatalf("expected open, got %s", state)
+	}
+	if opened != 1 {
+		t.Fatalf("expected exactly one transition, got %d", opened)
+	}
+}
+
+func benchmarkBreaker(b *testing.B, cb barbarian.CircuitBreaker) {
+	req := func() (interface{}, error) { return nil, nil }
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cb.Execute(req)
+		}
+	})
+}
+
+func BenchmarkCircuitBreakerParallel(b *testing.B) {
+	benchmarkBreaker(b, NewCircuitBreaker(Settings{}))
+}
+
+func BenchmarkAtomicCircuitBreakerParallel(b *testing.B) {
+	benchmarkBreaker(b, NewAtomicCircuitBreaker(Settings{}))
+}
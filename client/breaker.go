@@ -1,8 +1,11 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"net"
 	"sync"
 	"time"
 
@@ -15,11 +18,15 @@ const (
 	StateClosed State = iota
 	StateHalfOpen
 	StateOpen
+	StateForcedOpen
+	StateForcedClosed
+	StateDisabled
 )
 
 var (
 	ErrTooManyRequests = errors.New("too many requests")
 	ErrOpenState       = errors.New("circuit breaker is open")
+	ErrUnknownState    = errors.New("unknown circuit breaker state")
 )
 
 func (s State) String() string {
@@ -30,25 +37,54 @@ func (s State) String() string {
 		return "half-open"
 	case StateOpen:
 		return "open"
+	case StateForcedOpen:
+		return "forced-open"
+	case StateForcedClosed:
+		return "forced-closed"
+	case StateDisabled:
+		return "disabled"
 	default:
 		return fmt.Sprintf("unknown state: %d", s)
 	}
 }
 
+func (s State) MarshalText() ([]byte, error) {
+	if s < StateClosed || s > StateDisabled {
+		return nil, ErrUnknownState
+	}
+	return []byte(s.String()), nil
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	for state := StateClosed; state <= StateDisabled; state++ {
+		if state.String() == string(text) {
+			*s = state
+			return nil
+		}
+	}
+	return ErrUnknownState
+}
+
 var _ ICounts = &Counts{}
 
 type ICounts interface {
 	onRequest()
 	onSuccess()
 	onFailure()
+	onSlowCall()
 }
 
 type Counts struct {
-	Requests             uint32
-	TotalSuccesses       uint32
-	TotalFailures        uint32
-	ConsecutiveSuccesses uint32
-	ConsecutiveFailures  uint32
+	Requests             uint64           `json:"requests"`
+	TotalSuccesses       uint64           `json:"total_successes"`
+	TotalFailures        uint64           `json:"total_failures"`
+	ConsecutiveSuccesses uint64           `json:"consecutive_successes"`
+	ConsecutiveFailures  uint64           `json:"consecutive_failures"`
+	TotalSlowCalls       uint64           `json:"total_slow_calls"`
+	FailureRate          float64          `json:"failure_rate"`
+	SlowCallRate         float64          `json:"slow_call_rate"`
+	Latency              LatencyHistogram `json:"latency"`
+	Window               RollingCounts    `json:"-"`
 }
 
 func (c *Counts) onRequest() {
@@ -67,12 +103,21 @@ func (c *Counts) onFailure() {
 	c.ConsecutiveSuccesses = 0
 }
 
+func (c *Counts) onSlowCall() {
+	c.TotalSlowCalls++
+}
+
 func (c *Counts) clear() {
 	c.Requests = 0
 	c.TotalSuccesses = 0
 	c.TotalFailures = 0
 	c.ConsecutiveSuccesses = 0
 	c.ConsecutiveFailures = 0
+	c.TotalSlowCalls = 0
+	c.FailureRate = 0
+	c.SlowCallRate = 0
+	c.Latency = LatencyHistogram{}
+	c.Window = RollingCounts{}
 }
 
 type Settings struct {
@@ -83,8 +128,68 @@ type Settings struct {
 	ReadyToTrip   func(counts Counts) bool
 	OnStateChange func(name string, from State, to State)
 	IsSuccessful  func(err error) bool
+
+	SlidingWindowSize    uint32
+	FailureRateThreshold float64
+	MinimumNumberOfCalls uint32
+
+	RollingWindowBuckets        int
+	RollingWindowBucketDuration time.Duration
+
+	SlowCallDurationThreshold time.Duration
+	SlowCallRateThreshold     float64
+
+	StateStore        StateStore
+	StateSyncInterval time.Duration
+
+	RampUpDuration       time.Duration
+	RampUpSteps          uint32
+	RampUpInitialPercent float64
+
+	OpenTimeoutBackoff barbarian.Backoff
+}
+
+type callResult struct {
+	success bool
+	timeout bool
+	ignored bool
+	latency time.Duration
+	err     error
+}
+
+type ignoredError struct {
+	err error
+}
+
+func (e *ignoredError) Error() string {
+	if e.err == nil {
+		return "ignored"
+	}
+	return e.err.Error()
+}
+
+func (e *ignoredError) Unwrap() error {
+	return e.err
+}
+
+func Ignore(err error) error {
+	return &ignoredError{err: err}
+}
+
+func unwrapIgnored(err error) (error, bool) {
+	var ignored *ignoredError
+	if errors.As(err, &ignored) {
+		return ignored.err, true
+	}
+	return err, false
 }
 
+type IgnoringBreaker interface {
+	ExecuteIgnoring(req func() (interface{}, error), ignore func(err error) bool) (interface{}, error)
+}
+
+var _ IgnoringBreaker = &CircuitBreaker{}
+
 var _ ICircuitBreaker = &CircuitBreaker{}
 
 type ICircuitBreaker interface {
@@ -95,9 +200,9 @@ type ICircuitBreaker interface {
 	Execute(req func() (interface{}, error)) (interface{}, error)
 
 	beforeRequest() (uint64, error)
-	afterRequest(before uint64, success bool)
-	onSuccess(state State, now time.Time)
-	onFailure(state State, now time.Time)
+	afterRequest(before uint64, res callResult)
+	onSuccess(state State, now time.Time, slow bool)
+	onFailure(state State, now time.Time, slow bool)
 	currentState(now time.Time) (State, uint64)
 	setState(state State, now time.Time)
 	toNewGeneration(now time.Time)
@@ -112,11 +217,38 @@ type CircuitBreaker struct {
 	isSuccessful  func(err error) bool
 	onStateChange func(name string, from State, to State)
 
+	window               *slidingWindow
+	failureRateThreshold float64
+	minimumNumberOfCalls uint32
+
+	slowCallDurationThreshold time.Duration
+	slowWindow                *slidingWindow
+	slowCallRateThreshold     float64
+
+	rolling *rollingWindow
+
+	rampUpDuration       time.Duration
+	rampUpSteps          uint32
+	rampUpInitialPercent float64
+
+	openTimeoutBackoff barbarian.Backoff
+	halfOpenFailures   int
+
+	store        StateStore
+	syncInterval time.Duration
+	nextSync     time.Time
+	nextSave     time.Time
+	pendingSave  *StoredState
+	storeMutex   sync.Mutex
+
 	mutex      sync.Mutex
 	state      State
 	generation uint64
 	counts     Counts
 	expiry     time.Time
+	changedAt  time.Time
+
+	events eventBus
 }
 
 var _ barbarian.CircuitBreaker = (*CircuitBreaker)(nil)
@@ -157,6 +289,71 @@ func NewCircuitBreaker(st Settings) *CircuitBreaker {
 		cb.isSuccessful = st.IsSuccessful
 	}
 
+	if st.SlidingWindowSize > 0 {
+		cb.window = newSlidingWindow(st.SlidingWindowSize)
+
+		if st.FailureRateThreshold <= 0 {
+			cb.failureRateThreshold = defaultFailureRateThreshold
+		} else {
+			cb.failureRateThreshold = st.FailureRateThreshold
+		}
+
+		if st.MinimumNumberOfCalls == 0 || st.MinimumNumberOfCalls > st.SlidingWindowSize {
+			cb.minimumNumberOfCalls = st.SlidingWindowSize
+		} else {
+			cb.minimumNumberOfCalls = st.MinimumNumberOfCalls
+		}
+	} else if st.MinimumNumberOfCalls == 0 {
+		cb.minimumNumberOfCalls = defaultMinimumNumberOfCalls
+	} else {
+		cb.minimumNumberOfCalls = st.MinimumNumberOfCalls
+	}
+
+	if st.SlowCallDurationThreshold > 0 {
+		cb.slowCallDurationThreshold = st.SlowCallDurationThreshold
+
+		if st.SlowCallRateThreshold <= 0 {
+			cb.slowCallRateThreshold = defaultSlowCallRateThreshold
+		} else {
+			cb.slowCallRateThreshold = st.SlowCallRateThreshold
+		}
+
+		if cb.window == nil {
+			cb.slowWindow = newSlidingWindow(max(defaultSlowCallWindowSize, cb.minimumNumberOfCalls))
+		}
+	}
+
+	if st.RollingWindowBuckets > 0 {
+		if st.RollingWindowBucketDuration <= 0 {
+			cb.rolling = newRollingWindow(st.RollingWindowBuckets, defaultRollingWindowBucketDuration)
+		} else {
+			cb.rolling = newRollingWindow(st.RollingWindowBuckets, st.RollingWindowBucketDuration)
+		}
+	}
+
+	if st.RampUpDuration > 0 || st.RampUpSteps > 0 {
+		cb.rampUpDuration = st.RampUpDuration
+		cb.rampUpSteps = st.RampUpSteps
+
+		if st.RampUpInitialPercent <= 0 || st.RampUpInitialPercent > 100 {
+			cb.rampUpInitialPercent = defaultRampUpInitialPercent
+		} else {
+			cb.rampUpInitialPercent = st.RampUpInitialPercent
+		}
+	}
+
+	cb.openTimeoutBackoff = st.OpenTimeoutBackoff
+
+	if st.StateStore != nil {
+		cb.store = st.StateStore
+
+		if st.StateSyncInterval <= 0 {
+			cb.syncInterval = defaultStateSyncInterval
+		} else {
+			cb.syncInterval = st.StateSyncInterval
+		}
+	}
+
 	cb.toNewGeneration(time.Now())
 
 	return cb
@@ -164,6 +361,13 @@ func NewCircuitBreaker(st Settings) *CircuitBreaker {
 
 const defaultInterval = time.Duration(0) * time.Second
 const defaultTimeout = time.Duration(60) * time.Second
+const defaultFailureRateThreshold = float64(50)
+const defaultSlowCallRateThreshold = float64(100)
+const defaultSlowCallWindowSize = uint32(100)
+const defaultMinimumNumberOfCalls = uint32(10)
+const defaultRampUpInitialPercent = float64(10)
+const defaultStateSyncInterval = time.Duration(1) * time.Second
+const defaultRollingWindowBucketDuration = time.Duration(1) * time.Second
 
 func defaultReadyToTrip(counts Counts) bool {
 	return counts.ConsecutiveFailures > 5
@@ -173,13 +377,22 @@ func defaultIsSuccessful(err error) bool {
 	return err == nil
 }
 
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 func (cb *CircuitBreaker) Name() string {
 	return cb.name
 }
 
 func (cb *CircuitBreaker) State() State {
-	cb.mutex.Lock()
-	defer cb.mutex.Unlock()
+	cb.lock()
+	defer cb.unlock()
 
 	now := time.Now()
 	state, _ := cb.currentState(now)
@@ -187,98 +400,345 @@ func (cb *CircuitBreaker) State() State {
 }
 
 func (cb *CircuitBreaker) Counts() Counts {
-	cb.mutex.Lock()
-	defer cb.mutex.Unlock()
+	cb.lock()
+	defer cb.unlock()
 
-	return cb.counts
+	return cb.countsAt(time.Now())
+}
+
+func (cb *CircuitBreaker) countsAt(now time.Time) Counts {
+	counts := cb.counts
+	if cb.rolling != nil {
+		counts.Window = cb.rolling.aggregate(now)
+	}
+	return counts
 }
 
 func (c *CircuitBreaker) IsCircuitBreakerOpen() bool {
-	return c.State() == StateOpen
+	state := c.State()
+	return state == StateOpen || state == StateForcedOpen
+}
+
+func (cb *CircuitBreaker) ForceOpen() {
+	cb.override(StateForcedOpen)
+}
+
+func (cb *CircuitBreaker) ForceClosed() {
+	cb.override(StateForcedClosed)
+}
+
+func (cb *CircuitBreaker) Disable() {
+	cb.override(StateDisabled)
+}
+
+func (cb *CircuitBreaker) Reset() {
+	cb.lock()
+	defer cb.unlock()
+
+	now := time.Now()
+	cb.currentState(now)
+	cb.transition(StateClosed, now)
+	cb.emit(Event{Type: EventReset, Time: now, To: StateClosed})
+}
+
+func (cb *CircuitBreaker) Subscribe(buffer int) (events <-chan Event, unsubscribe func()) {
+	return cb.events.subscribe(buffer)
+}
+
+func (cb *CircuitBreaker) DroppedEvents() uint64 {
+	return cb.events.dropped.Load()
+}
+
+func (cb *CircuitBreaker) emit(event Event) {
+	if !cb.events.active() {
+		return
+	}
+
+	event.Name = cb.name
+	cb.events.publish(event)
+}
+
+func (cb *CircuitBreaker) override(state State) {
+	cb.lock()
+	defer cb.unlock()
+
+	now := time.Now()
+	cb.currentState(now)
+	cb.setState(state, now)
 }
 
 func (cb *CircuitBreaker) Execute(req func() (interface{}, error)) (interface{}, error) {
+	return cb.execute(req, nil)
+}
+
+func (cb *CircuitBreaker) ExecuteIgnoring(req func() (interface{}, error), ignore func(err error) bool) (interface{}, error) {
+	return cb.execute(req, ignore)
+}
+
+func ExecuteContext[T any](ctx context.Context, cb barbarian.CircuitBreaker, req func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	call := func() (interface{}, error) {
+		return req(ctx)
+	}
+
+	var result interface{}
+	var err error
+	if executor, ok := cb.(IgnoringBreaker); ok {
+		result, err = executor.ExecuteIgnoring(call, func(err error) bool {
+			return isContextError(ctx, err)
+		})
+	} else {
+		var ignoredErr error
+		var ignored bool
+		result, err = cb.Execute(func() (interface{}, error) {
+			result, err := call()
+			if err, ignored = unwrapIgnored(err); ignored || isContextError(ctx, err) {
+				ignoredErr, ignored = err, true
+				return result, nil
+			}
+			return result, err
+		})
+		if ignored {
+			err = ignoredErr
+		}
+	}
+
+	value, ok := result.(T)
+	if !ok {
+		return zero, err
+	}
+	return value, err
+}
+
+func isContextError(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err())
+}
+
+func (cb *CircuitBreaker) execute(req func() (interface{}, error), ignore func(err error) bool) (interface{}, error) {
 	generation, err := cb.beforeRequest()
 	if err != nil {
 		return nil, err
 	}
 
+	start := time.Now()
 	defer func() {
 		e := recover()
 		if e != nil {
-			cb.afterRequest(generation, false)
+			cb.afterRequest(generation, callResult{latency: time.Since(start)})
 			panic(e)
 		}
 	}()
 
 	result, err := req()
-	cb.afterRequest(generation, cb.isSuccessful(err))
+	err, ignored := unwrapIgnored(err)
+	cb.afterRequest(generation, callResult{
+		success: cb.isSuccessful(err),
+		timeout: isTimeout(err),
+		ignored: ignored || (ignore != nil && ignore(err)),
+		latency: time.Since(start),
+		err:     err,
+	})
 	return result, err
 }
 
+func (cb *CircuitBreaker) Allow() (done func(success bool), err error) {
+	generation, err := cb.beforeRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() {
+			cb.afterRequest(generation, callResult{success: success, latency: time.Since(start)})
+		})
+	}, nil
+}
+
 func (cb *CircuitBreaker) beforeRequest() (uint64, error) {
-	cb.mutex.Lock()
-	defer cb.mutex.Unlock()
+	cb.lock()
+	defer cb.unlock()
 
 	now := time.Now()
 	state, generation := cb.currentState(now)
 
-	if state == StateOpen {
-		return generation, ErrOpenState
-	} else if state == StateHalfOpen && cb.counts.Requests >= cb.maxRequests {
-		return generation, ErrTooManyRequests
+	if state == StateDisabled {
+		return generation, nil
+	} else if state == StateOpen || state == StateForcedOpen {
+		return generation, cb.reject(state, now, ErrOpenState)
+	} else if state == StateHalfOpen && cb.rampingUp() {
+		if rand.Float64()*100 >= cb.rampUpPercent(now) {
+			return generation, cb.reject(state, now, ErrTooManyRequests)
+		}
+	} else if state == StateHalfOpen && cb.counts.Requests >= uint64(cb.maxRequests) {
+		return generation, cb.reject(state, now, ErrTooManyRequests)
 	}
 
 	cb.counts.onRequest()
 	return generation, nil
 }
 
-func (cb *CircuitBreaker) afterRequest(before uint64, success bool) {
-	cb.mutex.Lock()
-	defer cb.mutex.Unlock()
+func (cb *CircuitBreaker) afterRequest(before uint64, res callResult) {
+	cb.lock()
+	defer cb.unlock()
 
 	now := time.Now()
 	state, generation := cb.currentState(now)
-	if generation != before {
+	if generation != before || state == StateDisabled {
 		return
 	}
 
-	if success {
-		cb.onSuccess(state, now)
+	if res.ignored {
+		if cb.counts.Requests > 0 {
+			cb.counts.Requests--
+		}
+		cb.emit(Event{Type: EventIgnoredError, Time: now, Duration: res.latency, From: state, To: state, Err: res.err})
+		return
+	}
+
+	if cb.rolling != nil {
+		cb.rolling.record(now, res)
+	}
+	cb.counts.Latency.record(res.latency)
+
+	slow := cb.slowCallDurationThreshold > 0 && res.latency >= cb.slowCallDurationThreshold
+	if res.success {
+		cb.emit(Event{Type: EventSuccess, Time: now, Duration: res.latency, From: state, To: state, Err: res.err})
+		cb.onSuccess(state, now, slow)
 	} else {
-		cb.onFailure(state, now)
+		cb.emit(Event{Type: EventFailure, Time: now, Duration: res.latency, From: state, To: state, Err: res.err})
+		cb.onFailure(state, now, slow)
 	}
+
+	cb.saveState(now, false)
 }
 
-func (cb *CircuitBreaker) onSuccess(state State, now time.Time) {
+func (cb *CircuitBreaker) onSuccess(state State, now time.Time, slow bool) {
 	switch state {
 	case StateClosed:
 		cb.counts.onSuccess()
+		cb.recordOutcome(false, slow)
+		if cb.slowCallRateExceeded() {
+			cb.setState(StateOpen, now)
+		}
+	case StateForcedClosed:
+		cb.counts.onSuccess()
+		cb.recordOutcome(false, slow)
 	case StateHalfOpen:
+		if slow {
+			cb.setState(StateOpen, now)
+			return
+		}
+
 		cb.counts.onSuccess()
-		if cb.counts.ConsecutiveSuccesses >= cb.maxRequests {
+		if cb.rampingUp() {
+			if cb.rampUpPercent(now) >= 100 {
+				cb.setState(StateClosed, now)
+			}
+		} else if cb.counts.ConsecutiveSuccesses >= uint64(cb.maxRequests) {
 			cb.setState(StateClosed, now)
 		}
 	}
 }
 
-func (cb *CircuitBreaker) onFailure(state State, now time.Time) {
+func (cb *CircuitBreaker) onFailure(state State, now time.Time, slow bool) {
 	switch state {
 	case StateClosed:
 		cb.counts.onFailure()
-		if cb.readyToTrip(cb.counts) {
+		cb.recordOutcome(true, slow)
+		if cb.readyToTrip(cb.countsAt(now)) || cb.failureRateExceeded() || cb.slowCallRateExceeded() {
 			cb.setState(StateOpen, now)
 		}
+	case StateForcedClosed:
+		cb.counts.onFailure()
+		cb.recordOutcome(true, slow)
 	case StateHalfOpen:
 		cb.setState(StateOpen, now)
 	}
 }
 
+func (cb *CircuitBreaker) rampingUp() bool {
+	return cb.rampUpDuration > 0 || cb.rampUpSteps > 0
+}
+
+func (cb *CircuitBreaker) rampUpPercent(now time.Time) float64 {
+	percent := cb.rampUpInitialPercent
+	remaining := 100 - cb.rampUpInitialPercent
+
+	if cb.rampUpDuration > 0 {
+		elapsed := now.Sub(cb.changedAt)
+		percent = max(percent, cb.rampUpInitialPercent+remaining*float64(elapsed)/float64(cb.rampUpDuration))
+	}
+
+	if cb.rampUpSteps > 0 {
+		percent = max(percent, cb.rampUpInitialPercent+remaining*float64(cb.counts.ConsecutiveSuccesses)/float64(cb.rampUpSteps))
+	}
+
+	return min(percent, 100)
+}
+
+func (cb *CircuitBreaker) recordOutcome(failure bool, slow bool) {
+	if slow {
+		cb.counts.onSlowCall()
+	}
+
+	if cb.window != nil {
+		cb.window.record(failure, slow)
+		cb.counts.FailureRate = cb.window.failureRate()
+		cb.counts.SlowCallRate = cb.window.slowCallRate()
+		return
+	}
+
+	if cb.slowWindow != nil {
+		cb.slowWindow.record(failure, slow)
+		cb.counts.SlowCallRate = cb.slowWindow.slowCallRate()
+	}
+}
+
+func (cb *CircuitBreaker) evaluatedCalls() uint64 {
+	if cb.window != nil {
+		return uint64(cb.window.calls)
+	}
+	if cb.slowWindow != nil {
+		return uint64(cb.slowWindow.calls)
+	}
+	return cb.counts.TotalSuccesses + cb.counts.TotalFailures
+}
+
+func (cb *CircuitBreaker) reject(state State, now time.Time, err error) error {
+	if cb.rolling != nil {
+		cb.rolling.reject(now)
+	}
+
+	cb.emit(Event{Type: EventCallNotPermitted, Time: now, From: state, To: state, Err: err})
+	return err
+}
+
+func (cb *CircuitBreaker) failureRateExceeded() bool {
+	if cb.window == nil || cb.window.calls < cb.minimumNumberOfCalls {
+		return false
+	}
+	return cb.counts.FailureRate >= cb.failureRateThreshold
+}
+
+func (cb *CircuitBreaker) slowCallRateExceeded() bool {
+	if cb.slowCallDurationThreshold == 0 || cb.evaluatedCalls() < uint64(cb.minimumNumberOfCalls) {
+		return false
+	}
+	return cb.counts.SlowCallRate >= cb.slowCallRateThreshold
+}
+
 func (cb *CircuitBreaker) currentState(now time.Time) (State, uint64) {
 	switch cb.state {
-	case StateClosed:
+	case StateClosed, StateForcedClosed:
 		if !cb.expiry.IsZero() && cb.expiry.Before(now) {
 			cb.toNewGeneration(now)
+			cb.saveState(now, false)
 		}
 	case StateOpen:
 		if cb.expiry.Before(now) {
@@ -293,31 +753,167 @@ func (cb *CircuitBreaker) setState(state State, now time.Time) {
 		return
 	}
 
+	cb.transition(state, now)
+}
+
+func (cb *CircuitBreaker) transition(state State, now time.Time) {
 	prev := cb.state
+	since := cb.changedAt
 	cb.state = state
+	cb.changedAt = now
+
+	switch {
+	case prev == StateHalfOpen && state == StateOpen:
+		cb.halfOpenFailures++
+	case state == StateClosed || state == StateForcedClosed:
+		cb.halfOpenFailures = 0
+	}
 
+	cb.resetWindows()
 	cb.toNewGeneration(now)
+	cb.saveState(now, true)
+
+	if prev != state {
+		cb.notifyStateChange(prev, state, now, since)
+	}
+}
+
+func (cb *CircuitBreaker) notifyStateChange(from State, to State, now time.Time, since time.Time) {
+	var duration time.Duration
+	if !since.IsZero() {
+		duration = now.Sub(since)
+	}
+	cb.emit(Event{Type: EventStateTransition, Time: now, Duration: duration, From: from, To: to})
 
 	if cb.onStateChange != nil {
-		cb.onStateChange(cb.name, prev, state)
+		cb.onStateChange(cb.name, from, to)
 	}
 }
 
+func (cb *CircuitBreaker) resetWindows() {
+	if cb.window != nil {
+		cb.window.reset()
+	}
+	if cb.slowWindow != nil {
+		cb.slowWindow.reset()
+	}
+	if cb.rolling != nil {
+		cb.rolling.reset()
+	}
+}
+
+func (cb *CircuitBreaker) lock() {
+	cb.syncStore()
+	cb.mutex.Lock()
+}
+
+func (cb *CircuitBreaker) unlock() {
+	cb.mutex.Unlock()
+	cb.syncStore()
+}
+
+func (cb *CircuitBreaker) syncStore() {
+	if cb.store == nil || !cb.storeMutex.TryLock() {
+		return
+	}
+	defer cb.storeMutex.Unlock()
+
+	cb.mutex.Lock()
+	now := time.Now()
+	pending := cb.pendingSave
+	cb.pendingSave = nil
+	load := !now.Before(cb.nextSync)
+	if load {
+		cb.nextSync = now.Add(cb.syncInterval)
+	}
+	cb.mutex.Unlock()
+
+	for pending != nil {
+		_ = cb.store.Save(cb.name, *pending)
+
+		cb.mutex.Lock()
+		pending = cb.pendingSave
+		cb.pendingSave = nil
+		cb.mutex.Unlock()
+	}
+
+	if !load {
+		return
+	}
+	stored, err := cb.store.Load(cb.name)
+	if err != nil {
+		return
+	}
+
+	cb.mutex.Lock()
+	cb.applyState(time.Now(), stored)
+	cb.mutex.Unlock()
+}
+
+func (cb *CircuitBreaker) applyState(now time.Time, stored StoredState) {
+	switch {
+	case stored.ChangedAt.After(cb.changedAt):
+		prev := cb.state
+		since := cb.changedAt
+		cb.state = stored.State
+		cb.changedAt = stored.ChangedAt
+		cb.generation = max(cb.generation+1, stored.Generation)
+		cb.counts = stored.Counts
+		cb.expiry = stored.Expiry
+
+		if prev != cb.state {
+			cb.resetWindows()
+			cb.notifyStateChange(prev, cb.state, now, since)
+		}
+	case stored.ChangedAt.Equal(cb.changedAt) && stored.Generation == cb.generation:
+		cb.counts = stored.Counts
+	}
+}
+
+func (cb *CircuitBreaker) saveState(now time.Time, force bool) {
+	if cb.store == nil || (!force && now.Before(cb.nextSave)) {
+		return
+	}
+	cb.nextSave = now.Add(cb.syncInterval)
+
+	cb.pendingSave = &StoredState{
+		State:      cb.state,
+		Generation: cb.generation,
+		Counts:     cb.counts,
+		Expiry:     cb.expiry,
+		ChangedAt:  cb.changedAt,
+	}
+}
+
+func (cb *CircuitBreaker) openTimeout() time.Duration {
+	if cb.openTimeoutBackoff == nil || cb.halfOpenFailures == 0 {
+		return cb.timeout
+	}
+	return max(cb.timeout, cb.openTimeoutBackoff.Next(cb.halfOpenFailures))
+}
+
 func (cb *CircuitBreaker) toNewGeneration(now time.Time) {
 	cb.generation++
 	cb.counts.clear()
+	if cb.window != nil {
+		cb.counts.FailureRate = cb.window.failureRate()
+		cb.counts.SlowCallRate = cb.window.slowCallRate()
+	}
+	if cb.slowWindow != nil {
+		cb.counts.SlowCallRate = cb.slowWindow.slowCallRate()
+	}
 
 	var zero time.Time
 	switch cb.state {
-	case StateClosed:
+	case StateClosed, StateForcedClosed:
 		if cb.interval == 0 {
 			cb.expiry = zero
 		} else {
 			cb.expiry = now.Add(cb.interval)
 		}
 	case StateOpen:
-		cb.expiry = now.Add(cb.timeout)
-	default: // StateHalfOpen
+		cb.expiry = now.Add(cb.openTimeout())
+	default: // StateHalfOpen, StateForcedOpen, StateDisabled
 		cb.expiry = zero
 	}
 }
@@ -1 +1,491 @@
 package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dyaksa/barbarian"
+)
+
+var errTest = errors.New("test error")
+
+func succeed(cb *CircuitBreaker) error {
+	_, err := cb.Execute(func() (interface{}, error) { return nil, nil })
+	return err
+}
+
+func fail(cb *CircuitBreaker) error {
+	_, err := cb.Execute(func() (interface{}, error) { return nil, errTest })
+	return err
+}
+
+func TestCircuitBreakerSlidingWindowTripsOnFailureRate(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:                 "sliding",
+		ReadyToTrip:          func(counts Counts) bool { return false },
+		SlidingWindowSize:    10,
+		FailureRateThreshold: 50,
+		MinimumNumberOfCalls: 4,
+	})
+
+	for i := 0; i < 6; i++ {
+		succeed(cb)
+	}
+	fail(cb)
+	fail(cb)
+	fail(cb)
+	fail(cb)
+
+	if got := cb.Counts().FailureRate; got != 40 {
+		t.Fatalf("expected failure rate 40, got %v", got)
+	}
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed state, got %s", cb.State())
+	}
+
+	fail(cb)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open state, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerSlidingWindowMinimumNumberOfCalls(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:                 "sliding",
+		ReadyToTrip:          func(counts Counts) bool { return false },
+		SlidingWindowSize:    100,
+		FailureRateThreshold: 50,
+		MinimumNumberOfCalls: 10,
+	})
+
+	for i := 0; i < 9; i++ {
+		fail(cb)
+	}
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed state before minimum number of calls, got %s", cb.State())
+	}
+
+	fail(cb)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open state, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerRollingWindowSurvivesInterval(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:     "rolling",
+		Interval: 10 * time.Millisecond,
+		ReadyToTrip: func(counts Counts) bool {
+			return counts.Window.Failures >= 3
+		},
+		RollingWindowBuckets:        10,
+		RollingWindowBucketDuration: time.Second,
+	})
+
+	fail(cb)
+	fail(cb)
+	time.Sleep(20 * time.Millisecond)
+
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed state, got %s", cb.State())
+	}
+	if got := cb.Counts(); got.TotalFailures != 0 || got.Window.Failures != 2 {
+		t.Fatalf("expected interval to clear counts but keep window, got %+v", got)
+	}
+
+	fail(cb)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open state, got %s", cb.State())
+	}
+
+	fail(cb)
+	if got := cb.Counts().Window.Rejections; got != 1 {
+		t.Fatalf("expected 1 rejection, got %d", got)
+	}
+}
+
+func TestCircuitBreakerTripsOnSlowCallRate(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:                      "slow",
+		SlowCallDurationThreshold: 5 * time.Millisecond,
+		SlowCallRateThreshold:     50,
+		MinimumNumberOfCalls:      4,
+	})
+
+	slow := func() {
+		cb.Execute(func() (interface{}, error) {
+			time.Sleep(10 * time.Millisecond)
+			return nil, nil
+		})
+	}
+
+	succeed(cb)
+	succeed(cb)
+	slow()
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed state before minimum number of calls, got %s", cb.State())
+	}
+	if got := cb.Counts(); got.TotalSlowCalls != 1 || got.TotalSuccesses != 3 {
+		t.Fatalf("unexpected counts: %+v", got)
+	}
+
+	slow()
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open state, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerSlowCallRateIsWindowed(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:                      "slow",
+		SlowCallDurationThreshold: time.Second,
+		SlowCallRateThreshold:     50,
+	})
+
+	call := func(latency time.Duration) {
+		generation, err := cb.beforeRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cb.afterRequest(generation, callResult{success: true, latency: latency})
+	}
+
+	for i := 0; i < 10000; i++ {
+		call(time.Millisecond)
+	}
+	for i := 0; i < 49; i++ {
+		call(2 * time.Second)
+	}
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed state below the threshold, got %s", cb.State())
+	}
+
+	call(2 * time.Second)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected recent slow calls to trip the breaker, got %s with %+v", cb.State(), cb.Counts())
+	}
+}
+
+func TestCircuitBreakerManualOverrides(t *testing.T) {
+	var transitions []State
+	cb := NewCircuitBreaker(Settings{
+		Name:        "manual",
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		OnStateChange: func(name string, from State, to State) {
+			transitions = append(transitions, to)
+		},
+	})
+
+	cb.ForceOpen()
+	if cb.State() != StateForcedOpen || !cb.IsCircuitBreakerOpen() {
+		t.Fatalf("expected forced-open state, got %s", cb.State())
+	}
+	if err := succeed(cb); !errors.Is(err, ErrOpenState) {
+		t.Fatalf("expected %v, got %v", ErrOpenState, err)
+	}
+
+	cb.ForceClosed()
+	fail(cb)
+	fail(cb)
+	if cb.State() != StateForcedClosed {
+		t.Fatalf("expected forced-closed state to ignore failures, got %s", cb.State())
+	}
+	if got := cb.Counts().TotalFailures; got != 2 {
+		t.Fatalf("expected forced-closed state to count failures, got %d", got)
+	}
+
+	cb.Disable()
+	fail(cb)
+	if cb.State() != StateDisabled || cb.Counts().Requests != 0 {
+		t.Fatalf("expected disabled state without counting, got %s %+v", cb.State(), cb.Counts())
+	}
+
+	cb.Reset()
+	fail(cb)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected automatic state machine after reset, got %s", cb.State())
+	}
+
+	want := []State{StateForcedOpen, StateForcedClosed, StateDisabled, StateClosed, StateOpen}
+	if len(transitions) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("expected transitions %v, got %v", want, transitions)
+		}
+	}
+}
+
+func TestCircuitBreakerRampUpBySteps(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:                 "ramp",
+		Timeout:              time.Millisecond,
+		ReadyToTrip:          func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		RampUpSteps:          4,
+		RampUpInitialPercent: 20,
+	})
+
+	fail(cb)
+	time.Sleep(5 * time.Millisecond)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("expected half-open state, got %s", cb.State())
+	}
+
+	cb.mutex.Lock()
+	percent := cb.rampUpPercent(time.Now())
+	cb.mutex.Unlock()
+	if percent != 20 {
+		t.Fatalf("expected initial ramp percent 20, got %v", percent)
+	}
+
+	successes := 0
+	for i := 0; i < 1000 && successes < 4; i++ {
+		if succeed(cb) == nil {
+			successes++
+		}
+		if successes < 4 && cb.State() != StateHalfOpen {
+			t.Fatalf("expected half-open state during ramp-up, got %s", cb.State())
+		}
+	}
+
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed state after ramp-up, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerRampUpFailureReopens(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:                 "ramp",
+		Timeout:              time.Millisecond,
+		ReadyToTrip:          func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		RampUpDuration:       time.Minute,
+		RampUpInitialPercent: 100,
+	})
+
+	fail(cb)
+	time.Sleep(5 * time.Millisecond)
+
+	fail(cb)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open state after failure during ramp-up, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerOpenTimeoutBackoff(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:               "backoff",
+		Timeout:            time.Second,
+		ReadyToTrip:        func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		OpenTimeoutBackoff: barbarian.NewExponentialBackoff(time.Second, time.Minute, 2, 0),
+	})
+
+	openFor := func() time.Duration {
+		cb.mutex.Lock()
+		defer cb.mutex.Unlock()
+		return cb.expiry.Sub(cb.changedAt)
+	}
+	halfOpen := func() {
+		cb.mutex.Lock()
+		defer cb.mutex.Unlock()
+		cb.setState(StateHalfOpen, time.Now())
+	}
+
+	fail(cb)
+	if got := openFor(); got != time.Second {
+		t.Fatalf("expected first open period of 1s, got %v", got)
+	}
+
+	halfOpen()
+	fail(cb)
+	if got := openFor(); got != 2*time.Second {
+		t.Fatalf("expected second open period of 2s, got %v", got)
+	}
+
+	halfOpen()
+	fail(cb)
+	if got := openFor(); got != 4*time.Second {
+		t.Fatalf("expected third open period of 4s, got %v", got)
+	}
+
+	halfOpen()
+	succeed(cb)
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed state, got %s", cb.State())
+	}
+
+	fail(cb)
+	if got := openFor(); got != time.Second {
+		t.Fatalf("expected open period to reset to 1s after closing, got %v", got)
+	}
+}
+
+func TestCircuitBreakerOpenTimeoutBackoffKeepsTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:               "backoff",
+		Timeout:            time.Minute,
+		ReadyToTrip:        func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		OpenTimeoutBackoff: barbarian.NewExponentialBackoff(time.Second, time.Hour, 2, 0),
+	})
+
+	openFor := func() time.Duration {
+		cb.mutex.Lock()
+		defer cb.mutex.Unlock()
+		return cb.expiry.Sub(cb.changedAt)
+	}
+	failProbe := func() {
+		cb.mutex.Lock()
+		cb.setState(StateHalfOpen, time.Now())
+		cb.mutex.Unlock()
+		fail(cb)
+	}
+
+	failProbe()
+	if got := openFor(); got != time.Minute {
+		t.Fatalf("expected the open period not to drop below the timeout, got %v", got)
+	}
+	for i := 0; i < 6; i++ {
+		failProbe()
+	}
+	if got := openFor(); got != 128*time.Second {
+		t.Fatalf("expected the open period to grow past the timeout, got %v", got)
+	}
+
+	cb.ForceClosed()
+	cb.mutex.Lock()
+	failures := cb.halfOpenFailures
+	cb.mutex.Unlock()
+	if failures != 0 {
+		t.Fatalf("expected forcing closed to reset the escalation, got %d failed probes", failures)
+	}
+	cb.Reset()
+
+	for i := 0; i < 6; i++ {
+		failProbe()
+	}
+	snapshot := cb.Snapshot()
+	snapshot.State = StateHalfOpen
+	if err := cb.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	fail(cb)
+	if got := openFor(); got != time.Minute {
+		t.Fatalf("expected restoring to reset the escalation, got %v", got)
+	}
+}
+
+func TestExecuteContext(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:        "context",
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+	})
+
+	value, err := ExecuteContext(context.Background(), cb, func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+	if err != nil || value != 42 {
+		t.Fatalf("expected 42, got %d %v", value, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = ExecuteContext(ctx, cb, func(ctx context.Context) (int, error) {
+		cancel()
+		return 0, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	if cb.State() != StateClosed || cb.Counts().TotalFailures != 0 {
+		t.Fatalf("expected cancellation not to be counted, got %s %+v", cb.State(), cb.Counts())
+	}
+
+	called := false
+	_, err = ExecuteContext(ctx, cb, func(ctx context.Context) (int, error) {
+		called = true
+		return 0, nil
+	})
+	if called || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected done context to be rejected immediately, got %v", err)
+	}
+	if got := cb.Counts().Requests; got != 1 {
+		t.Fatalf("expected 1 request, got %d", got)
+	}
+}
+
+type externalBreaker struct {
+	successes int
+	failures  int
+	ignored   int
+}
+
+func (b *externalBreaker) Name() string {
+	return "external"
+}
+
+func (b *externalBreaker) Execute(req func() (interface{}, error)) (interface{}, error) {
+	return b.ExecuteIgnoring(req, func(err error) bool { return false })
+}
+
+func (b *externalBreaker) ExecuteIgnoring(req func() (interface{}, error), ignore func(err error) bool) (interface{}, error) {
+	result, err := req()
+	switch {
+	case err == nil:
+		b.successes++
+	case ignore(err):
+		b.ignored++
+	default:
+		b.failures++
+	}
+	return result, err
+}
+
+func TestExecuteContextIgnoringBreaker(t *testing.T) {
+	breaker := &externalBreaker{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := ExecuteContext(ctx, breaker, func(ctx context.Context) (int, error) {
+		cancel()
+		return 0, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	if breaker.ignored != 1 || breaker.successes != 0 || breaker.failures != 0 {
+		t.Fatalf("expected cancellation to be ignored, got %+v", *breaker)
+	}
+}
+
+func TestCircuitBreakerAllow(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:        "allow",
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 2 },
+	})
+
+	done, err := cb.Allow()
+	if err != nil {
+		t.Fatalf("expected request to be allowed, got %v", err)
+	}
+	done(false)
+	done(false)
+	if got := cb.Counts().TotalFailures; got != 1 {
+		t.Fatalf("expected done to be reported once, got %d failures", got)
+	}
+
+	late, _ := cb.Allow()
+	cb.ForceOpen()
+	cb.Reset()
+	late(false)
+	if got := cb.Counts(); got.TotalFailures != 0 || got.Requests != 0 {
+		t.Fatalf("expected late report from old generation to be ignored, got %+v", got)
+	}
+
+	done, _ = cb.Allow()
+	done(false)
+	done, _ = cb.Allow()
+	done(false)
+	if _, err := cb.Allow(); !errors.Is(err, ErrOpenState) {
+		t.Fatalf("expected %v, got %v", ErrOpenState, err)
+	}
+}
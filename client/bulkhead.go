@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var ErrBulkheadFull = errors.New("bulkhead is full")
+
+type BulkheadSettings struct {
+	MaxConcurrentCalls int
+	MaxQueuedCalls     int
+	MaxWait            time.Duration
+}
+
+type Bulkhead struct {
+	slots     chan struct{}
+	maxQueued int64
+	maxWait   time.Duration
+
+	queued atomic.Int64
+}
+
+func NewBulkhead(st BulkheadSettings) *Bulkhead {
+	b := new(Bulkhead)
+
+	if st.MaxConcurrentCalls <= 0 {
+		b.slots = make(chan struct{}, defaultMaxConcurrentCalls)
+	} else {
+		b.slots = make(chan struct{}, st.MaxConcurrentCalls)
+	}
+
+	if st.MaxQueuedCalls > 0 {
+		b.maxQueued = int64(st.MaxQueuedCalls)
+	}
+	b.maxWait = st.MaxWait
+
+	return b
+}
+
+const defaultMaxConcurrentCalls = 100
+
+func (b *Bulkhead) InFlight() int {
+	return len(b.slots)
+}
+
+func (b *Bulkhead) Queued() int {
+	return int(b.queued.Load())
+}
+
+func (b *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	default:
+	}
+
+	if b.queued.Add(1) > b.maxQueued {
+		b.queued.Add(-1)
+		return nil, ErrBulkheadFull
+	}
+	defer b.queued.Add(-1)
+
+	var timeout <-chan time.Time
+	if b.maxWait > 0 {
+		timer := time.NewTimer(b.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	case <-timeout:
+		return nil, ErrBulkheadFull
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *Bulkhead) tryAcquire() (release func(), ok bool) {
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, true
+	default:
+		return nil, false
+	}
+}
+
+func (b *Bulkhead) release() {
+	<-b.slots
+}
+
+func (c *Client) Bulkhead(req *http.Request) *Bulkhead {
+	if c.bulkheads == nil {
+		return nil
+	}
+	return c.bulkheads.get(c.breakerKey(req))
+}
+
+func (c *Client) acquireBulkhead(req *http.Request) (release func(), err error) {
+	bulkhead := c.Bulkhead(req)
+	if bulkhead == nil {
+		return func() {}, nil
+	}
+	return bulkhead.Acquire(req.Context())
+}
+
+func (c *Client) tryAcquireBulkhead(req *http.Request) (release func(), ok bool) {
+	bulkhead := c.Bulkhead(req)
+	if bulkhead == nil {
+		return func() {}, true
+	}
+	return bulkhead.tryAcquire()
+}
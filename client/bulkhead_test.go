@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBulkheadQueuesAndRejects(t *testing.T) {
+	b := NewBulkhead(BulkheadSettings{
+		MaxConcurrentCalls: 1,
+		MaxQueuedCalls:     1,
+		MaxWait:            20 * time.Millisecond,
+	})
+
+	release, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Acquire(context.Background()); err != ErrBulkheadFull {
+		t.Fatalf("expected %v after max wait, got %v", ErrBulkheadFull, err)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		release, err := b.Acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	for b.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := b.Acquire(context.Background()); err != ErrBulkheadFull {
+		t.Fatalf("expected %v with a full queue, got %v", ErrBulkheadFull, err)
+	}
+
+	release()
+	if err := <-acquired; err != nil {
+		t.Fatalf("expected queued call to acquire a slot, got %v", err)
+	}
+	if b.InFlight() != 0 || b.Queued() != 0 {
+		t.Fatalf("expected empty bulkhead, got %d in flight and %d queued", b.InFlight(), b.Queued())
+	}
+}
+
+func TestBulkheadHonoursContext(t *testing.T) {
+	b := NewBulkhead(BulkheadSettings{MaxConcurrentCalls: 1, MaxQueuedCalls: 1})
+
+	release, _ := b.Acquire(context.Background())
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := b.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestClientBulkheadFallsBack(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	c := NewClient(&Config{
+		Name:     "test",
+		Bulkhead: &BulkheadSettings{MaxConcurrentCalls: 1},
+	})
+
+	var fallback atomic.Pointer[http.Response]
+	c.FallbackFunc(func() (*http.Response, error) { return fallback.Load(), nil })
+
+	go c.Get(context.Background(), server.URL)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	for c.Bulkhead(req).InFlight() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := c.Do(req); !errors.Is(err, ErrBulkheadFull) {
+		t.Fatalf("expected %v, got %v", ErrBulkheadFull, err)
+	}
+
+	fallback.Store(&http.Response{StatusCode: http.StatusServiceUnavailable})
+
+	resp, err := c.Get(context.Background(), server.URL)
+	if err != nil || resp != fallback.Load() {
+		t.Fatalf("expected fallback response, got %v, %v", resp, err)
+	}
+}
+
+func TestClientBulkheadHoldsSlotUntilBodyClosed(t *testing.T) {
+	server := newTestServer(http.StatusOK)
+	defer server.Close()
+
+	c := NewClient(&Config{
+		Name:     "test",
+		Bulkhead: &BulkheadSettings{MaxConcurrentCalls: 1},
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	bulkhead := c.Bulkhead(req)
+
+	for _, do := range []func() (*http.Response, error){
+		func() (*http.Response, error) { return c.Get(context.Background(), server.URL) },
+		func() (*http.Response, error) { return c.Do(req) },
+	} {
+		resp, err := do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bulkhead.InFlight() != 1 {
+			t.Fatalf("expected the slot to be held while the body is open, got %d in flight", bulkhead.InFlight())
+		}
+		if _, err := c.Get(context.Background(), server.URL); !errors.Is(err, ErrBulkheadFull) {
+			t.Fatalf("expected %v, got %v", ErrBulkheadFull, err)
+		}
+
+		resp.Body.Close()
+		resp.Body.Close()
+		if bulkhead.InFlight() != 0 {
+			t.Fatalf("expected the slot to be released once, got %d in flight", bulkhead.InFlight())
+		}
+	}
+
+	if _, err := c.Get(context.Background(), "http://127.0.0.1:0"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if bulkhead.InFlight() != 0 {
+		t.Fatalf("expected the slot to be released on error, got %d in flight", bulkhead.InFlight())
+	}
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota
+	OutcomeFailure
+	OutcomeIgnore
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomeFailure:
+		return "failure"
+	case OutcomeIgnore:
+		return "ignore"
+	default:
+		return fmt.Sprintf("unknown outcome: %d", o)
+	}
+}
+
+type Classifier func(req *http.Request, resp *http.Response, err error) Outcome
+
+func StatusClassifier(failureThreshold int, ignoredStatusCodes ...int) Classifier {
+	ignored := make(map[int]bool, len(ignoredStatusCodes))
+	for _, code := range ignoredStatusCodes {
+		ignored[code] = true
+	}
+
+	return func(req *http.Request, resp *http.Response, err error) Outcome {
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return OutcomeIgnore
+			}
+			return OutcomeFailure
+		}
+
+		if ignored[resp.StatusCode] {
+			return OutcomeIgnore
+		}
+
+		if failureThreshold > 0 && resp.StatusCode >= failureThreshold {
+			return OutcomeFailure
+		}
+		return OutcomeSuccess
+	}
+}
+
+func legacyClassifier(considerServerErrorAsFailure bool, serverErrorThreshold int) Classifier {
+	if !considerServerErrorAsFailure {
+		return StatusClassifier(0)
+	}
+	return StatusClassifier(serverErrorThreshold)
+}
+
+func (c *Client) classifyResponse(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	outcome := c.classifier(req, resp, err)
+
+	if err != nil {
+		c.reportError(req, err)
+		err = errors.Wrap(err, "failed to execute request")
+		if outcome != OutcomeFailure {
+			return resp, Ignore(err)
+		}
+		return resp, err
+	}
+
+	switch outcome {
+	case OutcomeFailure:
+		c.reportError(req, fmt.Errorf("response status code: %d", resp.StatusCode))
+		return resp, fmt.Errorf("server error: %d", resp.StatusCode)
+	case OutcomeIgnore:
+		c.reportResponse(req, resp)
+		return resp, Ignore(nil)
+	default:
+		c.reportResponse(req, resp)
+		return resp, nil
+	}
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dyaksa/barbarian"
+)
+
+func TestStatusClassifier(t *testing.T) {
+	classify := StatusClassifier(500, http.StatusTooManyRequests)
+	req, _ := http.NewRequest(http.MethodGet, "http://api.local", nil)
+
+	tests := []struct {
+		resp *http.Response
+		err  error
+		want Outcome
+	}{
+		{&http.Response{StatusCode: http.StatusOK}, nil, OutcomeSuccess},
+		{&http.Response{StatusCode: http.StatusNotFound}, nil, OutcomeSuccess},
+		{&http.Response{StatusCode: http.StatusTooManyRequests}, nil, OutcomeIgnore},
+		{&http.Response{StatusCode: http.StatusBadGateway}, nil, OutcomeFailure},
+		{nil, context.Canceled, OutcomeIgnore},
+		{nil, errTest, OutcomeFailure},
+	}
+
+	for _, tt := range tests {
+		if got := classify(req, tt.resp, tt.err); got != tt.want {
+			t.Errorf("classify(%v, %v) = %s, want %s", tt.resp, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestClientClassifier(t *testing.T) {
+	status := http.StatusTooManyRequests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	c := NewClient(&Config{
+		Name:        "test",
+		BaseUrl:     server.URL,
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		Classifier:  StatusClassifier(500, http.StatusTooManyRequests),
+	})
+
+	resp, err := c.Get(context.Background(), "/")
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected ignored response to be returned, got %v %v", resp, err)
+	}
+	resp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err = c.Do(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected ignored response to be returned, got %v %v", resp, err)
+	}
+	resp.Body.Close()
+
+	cb := c.Breaker(req).(*CircuitBreaker)
+	if got := cb.Counts(); got.Requests != 0 || cb.State() != StateClosed {
+		t.Fatalf("expected ignored responses not to be counted, got %s %+v", cb.State(), got)
+	}
+
+	status = http.StatusInternalServerError
+	if _, err := c.Get(context.Background(), "/"); err == nil {
+		t.Fatalf("expected server error")
+	}
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open state, got %s", cb.State())
+	}
+}
+
+func TestIgnoreIsNotCounted(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{Name: "ignore"})
+
+	_, err := cb.Execute(func() (interface{}, error) {
+		return nil, Ignore(errTest)
+	})
+	if err != errTest {
+		t.Fatalf("expected unwrapped error, got %v", err)
+	}
+	if got := cb.Counts(); got.Requests != 0 || got.TotalFailures != 0 {
+		t.Fatalf("expected ignored call not to be counted, got %+v", got)
+	}
+}
+
+type countingBreaker struct {
+	failures int
+}
+
+func (b *countingBreaker) Name() string {
+	return "counting"
+}
+
+func (b *countingBreaker) Execute(req func() (interface{}, error)) (interface{}, error) {
+	result, err := req()
+	if err != nil {
+		b.failures++
+	}
+	return result, err
+}
+
+func TestClientIgnoreWithPlainBreaker(t *testing.T) {
+	server := newTestServer(http.StatusTooManyRequests)
+	defer server.Close()
+
+	breaker := &countingBreaker{}
+	c := NewClient(&Config{
+		Name:           "test",
+		Classifier:     StatusClassifier(500, http.StatusTooManyRequests),
+		BreakerFactory: func(name string) barbarian.CircuitBreaker { return breaker },
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("expected ignored response without error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", resp.StatusCode)
+	}
+	if breaker.failures != 0 {
+		t.Fatalf("expected ignored response not to be counted as a failure, got %d", breaker.failures)
+	}
+}
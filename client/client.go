@@ -3,10 +3,10 @@ package client
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/dyaksa/barbarian"
@@ -27,36 +27,103 @@ type Config struct {
 	ReadyToTrip   func(counts Counts) bool
 	OnStateChange func(name string, to State, from State)
 
+	SlidingWindowSize    uint32
+	FailureRateThreshold float64
+	MinimumNumberOfCalls uint32
+
+	RollingWindowBuckets        int
+	RollingWindowBucketDuration time.Duration
+
+	SlowCallDurationThreshold time.Duration
+	SlowCallRateThreshold     float64
+
+	BreakerKeyFunc     KeyFunc
+	BreakerIdleTimeout time.Duration
+	BreakerFactory     func(name string) barbarian.CircuitBreaker
+
+	StateStore        StateStore
+	StateSyncInterval time.Duration
+
+	RampUpDuration       time.Duration
+	RampUpSteps          uint32
+	RampUpInitialPercent float64
+
+	OpenTimeoutBackoff barbarian.Backoff
+
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+
+	Classifier Classifier
+
+	HealthProbe *HealthProbe
+
+	Bulkhead *BulkheadSettings
+
+	ConcurrencyLimit *ConcurrencyLimitSettings
+
+	Hedge *HedgePolicy
+
+	Coalesce *CoalesceSettings
+
+	// Deprecated: use Classifier, for example StatusClassifier(ServerErrorThreshold).
 	ConsiderServerErrorAsFailure bool
-	ServerErrorThreshold         int
+	// Deprecated: use Classifier, for example StatusClassifier(ServerErrorThreshold).
+	ServerErrorThreshold int
+
+	RetryCount  int
+	RetryBudget *RetryBudgetSettings
+	RetryPolicy *RetryPolicy
 
-	RetryCount int
+	AttemptTimeout time.Duration
+	MaxElapsedTime time.Duration
 }
 
 type Client struct {
 	httpClient *http.Client
-	breaker    *CircuitBreaker
+	breakers   *registry[barbarian.CircuitBreaker]
+	breakerKey KeyFunc
+	bulkheads  *registry[*Bulkhead]
+	limiters   *registry[*ConcurrencyLimiter]
+	hedger     *hedger
+	coalescer  *coalescer
 
-	baseUrl                      string
-	considerServerErrorAsFailure bool
-	serverErrorThreshold         int
-	plugins                      map[string][]barbarian.Plugin
+	baseUrl    string
+	classifier Classifier
+	plugins    map[string][]barbarian.Plugin
 
 	fallback func() (*http.Response, error)
 
-	retrier    barbarian.Retriable
-	retryCount int
+	retrier     barbarian.Retriable
+	retryCount  int
+	retryPolicy *retryPolicy
+
+	attemptTimeout time.Duration
+	maxElapsedTime time.Duration
+
+	retryBudgets       *registry[*retryBudget]
+	retryBudgetPerHost bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
 }
 
 func NewClient(config *Config) (c *Client) {
 	c = &Client{
-		httpClient:                   createHTTPClient(),
-		plugins:                      make(map[string][]barbarian.Plugin),
-		retrier:                      barbarian.NewNoRetrier(),
-		retryCount:                   config.RetryCount - 1,
-		baseUrl:                      config.BaseUrl,
-		considerServerErrorAsFailure: config.ConsiderServerErrorAsFailure,
-		serverErrorThreshold:         config.ServerErrorThreshold,
+		httpClient: createHTTPClient(),
+		plugins:    make(map[string][]barbarian.Plugin),
+		retrier:    barbarian.NewNoRetrier(),
+		retryCount: config.RetryCount - 1,
+		baseUrl:    config.BaseUrl,
+		classifier: config.Classifier,
+		done:       make(chan struct{}),
+
+		attemptTimeout: config.AttemptTimeout,
+		maxElapsedTime: config.MaxElapsedTime,
+	}
+
+	if c.classifier == nil {
+		c.classifier = legacyClassifier(config.ConsiderServerErrorAsFailure, config.ServerErrorThreshold)
 	}
 
 	if config.HTTPTimeout != 0 {
@@ -71,18 +138,107 @@ func NewClient(config *Config) (c *Client) {
 		c.retryCount = 0
 	}
 
-	c.breaker = NewCircuitBreaker(Settings{
+	c.breakerKey = config.BreakerKeyFunc
+	if c.breakerKey == nil {
+		c.breakerKey = defaultKeyFunc
+	}
+
+	settings := Settings{
 		Name:          config.Name,
 		MaxRequests:   config.MaxRequests,
 		Timeout:       config.Timeout,
 		Interval:      config.Interval,
 		ReadyToTrip:   config.ReadyToTrip,
 		OnStateChange: config.OnStateChange,
-	})
+
+		SlidingWindowSize:    config.SlidingWindowSize,
+		FailureRateThreshold: config.FailureRateThreshold,
+		MinimumNumberOfCalls: config.MinimumNumberOfCalls,
+
+		RollingWindowBuckets:        config.RollingWindowBuckets,
+		RollingWindowBucketDuration: config.RollingWindowBucketDuration,
+
+		SlowCallDurationThreshold: config.SlowCallDurationThreshold,
+		SlowCallRateThreshold:     config.SlowCallRateThreshold,
+
+		StateStore:        config.StateStore,
+		StateSyncInterval: config.StateSyncInterval,
+
+		RampUpDuration:       config.RampUpDuration,
+		RampUpSteps:          config.RampUpSteps,
+		RampUpInitialPercent: config.RampUpInitialPercent,
+
+		OpenTimeoutBackoff: config.OpenTimeoutBackoff,
+	}
+
+	c.breakers = newRegistry(func(key string) barbarian.CircuitBreaker {
+		st := settings
+		if key != "" {
+			st.Name = settings.Name + ":" + key
+		}
+
+		if config.BreakerFactory != nil {
+			return config.BreakerFactory(st.Name)
+		}
+		return NewCircuitBreaker(st)
+	}, config.BreakerIdleTimeout)
+
+	if config.Bulkhead != nil {
+		bulkhead := *config.Bulkhead
+		c.bulkheads = newRegistry(func(key string) *Bulkhead {
+			return NewBulkhead(bulkhead)
+		}, config.BreakerIdleTimeout)
+	}
+
+	if config.ConcurrencyLimit != nil {
+		limit := *config.ConcurrencyLimit
+		c.limiters = newRegistry(func(key string) *ConcurrencyLimiter {
+			return NewConcurrencyLimiter(limit)
+		}, config.BreakerIdleTimeout)
+	}
+
+	if config.SnapshotPath != "" {
+		_ = c.loadSnapshot(config.SnapshotPath)
+
+		interval := config.SnapshotInterval
+		if interval <= 0 {
+			interval = defaultSnapshotInterval
+		}
+
+		c.wg.Add(1)
+		go c.persistSnapshots(config.SnapshotPath, interval)
+	}
+
+	if config.Hedge != nil {
+		c.hedger = newHedger(*config.Hedge)
+	}
+
+	if config.RetryBudget != nil {
+		budget := *config.RetryBudget
+		c.retryBudgets = newRegistry(func(key string) *retryBudget {
+			return newRetryBudget(budget)
+		}, config.BreakerIdleTimeout)
+		c.retryBudgetPerHost = budget.PerHost
+	}
+
+	if config.RetryPolicy != nil {
+		c.retryPolicy = newRetryPolicy(*config.RetryPolicy)
+	}
+
+	if config.Coalesce != nil {
+		c.coalescer = newCoalescer(*config.Coalesce)
+	}
+
+	if config.HealthProbe != nil {
+		c.wg.Add(1)
+		go c.runHealthProbe(*config.HealthProbe)
+	}
 
 	return c
 }
 
+const defaultSnapshotInterval = time.Duration(10) * time.Second
+
 func createHTTPClient() *http.Client {
 	return &http.Client{
 		Transport: createHTTPTransport(),
@@ -112,36 +268,42 @@ func createHTTPTransport() *http.Transport {
 var _ barbarian.Client = (*Client)(nil)
 
 func (c *Client) executeRequest(ctx context.Context, method, url string, options ...barbarian.RequestOption) (r *http.Response, err error) {
-	resp, err := c.breaker.Execute(func() (interface{}, error) {
-		req, err := http.NewRequestWithContext(ctx, method, url, nil)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to create request")
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	for _, option := range options {
+		if err := option(req); err != nil {
+			return nil, errors.Wrap(err, "failed to apply request option")
 		}
+	}
 
-		c.reportRequest(req)
+	return c.coalesce(req, c.execute)
+}
 
-		for _, option := range options {
-			if err := option(req); err != nil {
-				return nil, errors.Wrap(err, "failed to apply request option")
-			}
+func (c *Client) execute(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	req, cancel := withTimeout(req, c.maxElapsedTime)
+
+	var resp *http.Response
+	release, err := c.acquireBulkhead(req)
+	if err == nil {
+		err = c.waitRateLimit(req)
+		if err == nil {
+			resp, err = ExecuteContext(ctx, c.Breaker(req), func(ctx context.Context) (*http.Response, error) {
+				return c.hedge(req, c.send)
+			})
 		}
-
-		resp, err := c.httpClient.Do(req)
 		if err != nil {
-			c.reportError(req, err)
-			return resp, errors.Wrap(err, "failed to execute request")
-		}
-
-		if c.considerServerErrorAsFailure && resp.StatusCode >= c.serverErrorThreshold {
-			c.reportError(req, fmt.Errorf("response status code: %d", resp.StatusCode))
-			return resp, fmt.Errorf("server error: %d", resp.StatusCode)
+			release()
 		}
-
-		c.reportResponse(req, resp)
-		return resp, nil
-	})
+	}
 
 	if err != nil {
+		closeResponse(resp)
+		cancel()
+
 		resp, errFallback := c.fallback()
 		if errFallback != nil {
 			return nil, errors.Wrap(err, "failed to execute request")
@@ -153,7 +315,10 @@ func (c *Client) executeRequest(ctx context.Context, method, url string, options
 		return resp, nil
 	}
 
-	return resp.(*http.Response), nil
+	return withCancelOnClose(resp, func() {
+		cancel()
+		release()
+	}), nil
 }
 
 func (c *Client) AddPlugin(plugin barbarian.Plugin) {
@@ -165,46 +330,116 @@ func (c *Client) FallbackFunc(f func() (*http.Response, error)) {
 	c.fallback = f
 }
 
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	c.wg.Wait()
+	return nil
+}
+
+func (c *Client) Breaker(req *http.Request) barbarian.CircuitBreaker {
+	return c.breakers.get(c.breakerKey(req))
+}
+
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.coalesce(req, c.do)
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
 	c.setRetrier()
 
-	resp, err := c.breaker.Execute(func() (interface{}, error) {
-		return c.executeWithRetry(req)
-	})
+	ctx := req.Context()
+	req, cancel := withTimeout(req, c.maxElapsedTime)
+
+	release, err := c.acquireBulkhead(req)
+	if err != nil {
+		cancel()
+		return c.handleError(err)
+	}
+
+	resp, err := c.executeWithRetry(ctx, req, c.Breaker(req))
 
 	if err != nil {
+		cancel()
+		release()
 		return c.handleError(err)
 	}
 
-	return resp.(*http.Response), nil
+	return withCancelOnClose(resp, func() {
+		cancel()
+		release()
+	}), nil
 }
 
-func (c *Client) executeWithRetry(req *http.Request) (*http.Response, error) {
+func (c *Client) executeWithRetry(ctx context.Context, req *http.Request, breaker barbarian.CircuitBreaker) (*http.Response, error) {
 	bodyReader, err := c.prepareRequestBody(req)
 	if err != nil {
 		return nil, err
 	}
 
-	if c.breaker.IsCircuitBreakerOpen() {
+	if isBreakerOpen(breaker) {
 		return nil, errors.Wrap(errors.New("circuit breaker is open"), "circuit breaker")
 	}
 
+	budget := c.budgetFor(req)
+	if budget != nil {
+		budget.onRequest(time.Now())
+	}
+
 	var lastError error
 	for attempt := 0; attempt <= c.retryCount; attempt++ {
-		resp, err := c.performRequest(req, bodyReader)
-		if err == nil && !c.isServerError(resp) {
+		if err := c.waitRateLimit(req); err != nil {
+			if lastError != nil {
+				return nil, lastError
+			}
+			return nil, err
+		}
+
+		var called, ignored bool
+		resp, err := ExecuteContext(ctx, breaker, func(ctx context.Context) (*http.Response, error) {
+			called = true
+			resp, err := c.performRequest(req, bodyReader)
+			_, ignored = unwrapIgnored(err)
+			return resp, err
+		})
+		if err == nil {
 			return resp, nil
 		}
+		if !called || ignored {
+			if lastError != nil {
+				closeResponse(resp)
+				return nil, lastError
+			}
+			return resp, err
+		}
+
+		retryable := c.retryPolicy == nil || c.retryPolicy.shouldRetry(req, resp, err)
+		closeResponse(resp)
+		lastError = errors.Wrap(err, "request failed")
+		if !retryable {
+			break
+		}
 
-		lastError = c.handleRequestError(err)
 		if attempt < c.retryCount {
-			c.waitBeforeRetry(attempt)
+			if budget != nil && !budget.allowRetry(time.Now()) {
+				c.reportError(req, ErrRetryBudgetExhausted)
+				break
+			}
+			if err := c.waitBeforeRetry(req.Context(), attempt); err != nil {
+				break
+			}
 		}
 	}
 
 	return nil, lastError
 }
 
+func isBreakerOpen(breaker barbarian.CircuitBreaker) bool {
+	cb, ok := breaker.(interface{ IsCircuitBreakerOpen() bool })
+	return ok && cb.IsCircuitBreakerOpen()
+}
+
 func (c *Client) prepareRequestBody(req *http.Request) (*bytes.Reader, error) {
 	if req.Body == nil {
 		return nil, nil
@@ -221,36 +456,64 @@ func (c *Client) prepareRequestBody(req *http.Request) (*bytes.Reader, error) {
 }
 
 func (c *Client) performRequest(req *http.Request, bodyReader *bytes.Reader) (*http.Response, error) {
-	c.reportRequest(req)
-
 	if bodyReader != nil {
 		_, _ = bodyReader.Seek(0, 0)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	return c.hedge(req, c.send)
+}
+
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	done, err := c.acquireLimit(req)
 	if err != nil {
-		c.reportError(req, err)
-		return nil, errors.Wrap(err, "failed to execute request")
+		return nil, Ignore(err)
 	}
 
-	c.reportResponse(req, resp)
-	return resp, nil
+	req, cancel := withTimeout(req, c.attemptTimeout)
+	c.reportRequest(req)
+
+	resp, err := c.httpClient.Do(req)
+	resp, err = c.classifyResponse(req, resp, err)
+	done(isFailure(err))
+	return withCancelOnClose(resp, cancel), err
 }
 
-func (c *Client) isServerError(resp *http.Response) bool {
-	return c.considerServerErrorAsFailure && resp.StatusCode >= c.serverErrorThreshold
+func withTimeout(req *http.Request, timeout time.Duration) (*http.Request, context.CancelFunc) {
+	if timeout <= 0 {
+		return req, func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	return req.WithContext(ctx), cancel
 }
 
-func (c *Client) handleRequestError(err error) error {
-	if err != nil {
-		return errors.Wrap(err, "request failed")
+func isFailure(err error) bool {
+	_, ignored := unwrapIgnored(err)
+	return err != nil && !ignored
+}
+
+func closeResponse(resp *http.Response) {
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
 	}
-	return errors.New("server error")
 }
 
-func (c *Client) waitBeforeRetry(attempt int) {
+func (c *Client) waitBeforeRetry(ctx context.Context, attempt int) error {
 	backoffTime := c.retrier.NextInterval(attempt)
-	time.Sleep(backoffTime)
+
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoffTime).After(deadline) {
+		return context.DeadlineExceeded
+	}
+
+	timer := time.NewTimer(backoffTime)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (c *Client) handleError(err error) (*http.Response, error) {
@@ -325,6 +588,17 @@ func (c *Client) reportError(req *http.Request, err error) {
 	}
 }
 
+func (c *Client) waitRateLimit(req *http.Request) error {
+	for _, plugin := range c.plugins["ratelimiter"] {
+		if limiter, ok := plugin.(barbarian.RateLimiterPlugins); ok {
+			if err := limiter.Wait(req); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (c *Client) setRetrier() {
 	for _, plugin := range c.plugins["retrier"] {
 		if retrier, ok := plugin.(barbarian.RetryPlugins); ok {
@@ -1 +1,291 @@
 package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dyaksa/barbarian"
+)
+
+func newTestServer(status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+}
+
+func TestClientBreakerPerHost(t *testing.T) {
+	healthy := newTestServer(http.StatusOK)
+	defer healthy.Close()
+	failing := newTestServer(http.StatusInternalServerError)
+	defer failing.Close()
+
+	c := NewClient(&Config{
+		Name:                         "test",
+		ConsiderServerErrorAsFailure: true,
+		ServerErrorThreshold:         500,
+		ReadyToTrip:                  func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		BreakerKeyFunc:               KeyByHost,
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, failing.URL, nil)
+	if _, err := c.Do(req); err == nil {
+		t.Fatalf("expected error from failing host")
+	}
+	if state := c.Breaker(req).(*CircuitBreaker).State(); state != StateOpen {
+		t.Fatalf("expected failing host breaker to be open, got %s", state)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, healthy.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("expected healthy host to be reachable, got %v", err)
+	}
+	resp.Body.Close()
+	if state := c.Breaker(req).(*CircuitBreaker).State(); state != StateClosed {
+		t.Fatalf("expected healthy host breaker to be closed, got %s", state)
+	}
+}
+
+func TestClientRateLimiterRejects(t *testing.T) {
+	server := newTestServer(http.StatusOK)
+	defer server.Close()
+
+	c := NewClient(&Config{Name: "test"})
+	c.AddPlugin(barbarian.NewRateLimiter(barbarian.RateLimiterSettings{
+		Default: barbarian.RateLimit{Rate: 0.001, Burst: 1},
+		Policy:  barbarian.RateLimitReject,
+	}))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if _, err := c.Get(context.Background(), server.URL); !errors.Is(err, barbarian.ErrRateLimited) {
+		t.Fatalf("expected %v, got %v", barbarian.ErrRateLimited, err)
+	}
+	if counts := c.Breaker(req).(*CircuitBreaker).Counts(); counts.Requests != 1 || counts.TotalFailures != 0 {
+		t.Fatalf("expected rate limited call not to be counted, got %+v", counts)
+	}
+}
+
+func TestClientRateLimiterWaitsPerHost(t *testing.T) {
+	limited := newTestServer(http.StatusOK)
+	defer limited.Close()
+	unlimited := newTestServer(http.StatusOK)
+	defer unlimited.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, limited.URL, nil)
+	c := NewClient(&Config{Name: "test"})
+	c.AddPlugin(barbarian.NewRateLimiter(barbarian.RateLimiterSettings{
+		Limits: map[string]barbarian.RateLimit{
+			req.URL.Host: {Rate: 20, Burst: 1},
+		},
+	}))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		resp, err := c.Get(context.Background(), limited.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("expected calls to wait for tokens, took %s", elapsed)
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := c.Get(context.Background(), unlimited.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.Get(ctx, limited.URL); !errors.Is(err, barbarian.ErrRateLimited) {
+		t.Fatalf("expected %v when the wait exceeds the deadline, got %v", barbarian.ErrRateLimited, err)
+	}
+}
+
+func TestClientRateLimitWaitIsNotTimedByBreaker(t *testing.T) {
+	server := newTestServer(http.StatusOK)
+	defer server.Close()
+
+	c := NewClient(&Config{
+		Name:                      "test",
+		RetryCount:                1,
+		SlowCallDurationThreshold: 20 * time.Millisecond,
+	})
+	c.AddPlugin(barbarian.NewRateLimiter(barbarian.RateLimiterSettings{
+		Default: barbarian.RateLimit{Rate: 20, Burst: 1},
+	}))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	for i := 0; i < 2; i++ {
+		resp, err := c.Get(context.Background(), server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		resp, err = c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if counts := c.Breaker(req).(*CircuitBreaker).Counts(); counts.TotalSuccesses != 4 || counts.TotalSlowCalls != 0 {
+		t.Fatalf("expected the rate limit wait not to count as latency, got %+v", counts)
+	}
+}
+
+func newFailingClient(config *Config, backoff time.Duration) *Client {
+	config.Name = "test"
+	config.Classifier = StatusClassifier(500)
+	config.ReadyToTrip = func(counts Counts) bool { return false }
+
+	c := NewClient(config)
+	c.AddPlugin(barbarian.NewRetrier(barbarian.NewConstantBackoff(backoff, 0)))
+	return c
+}
+
+func TestClientBackoffAbortsOnCancel(t *testing.T) {
+	server := newTestServer(http.StatusInternalServerError)
+	defer server.Close()
+
+	c := newFailingClient(&Config{RetryCount: 3}, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("expected failing request")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected the backoff to abort on cancel, took %s", elapsed)
+	}
+}
+
+func TestClientSkipsRetryPastDeadline(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	c := newFailingClient(&Config{RetryCount: 3}, 500*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	c.Do(req)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("expected the retry past the deadline to be skipped, took %s", elapsed)
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("expected 1 attempt, got %d", hits.Load())
+	}
+}
+
+func TestClientAttemptTimeoutAndMaxElapsedTime(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	c := newFailingClient(&Config{
+		RetryCount:     10,
+		AttemptTimeout: 30 * time.Millisecond,
+		MaxElapsedTime: 100 * time.Millisecond,
+	}, 0)
+
+	start := time.Now()
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("expected timed out request")
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Fatalf("expected the call to stop at the max elapsed time, took %s", elapsed)
+	}
+	if attempts := hits.Load(); attempts < 2 || attempts > 4 {
+		t.Fatalf("expected attempts to time out individually, got %d", attempts)
+	}
+}
+
+func TestClientMaxElapsedTimeTripsBreaker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	for name, do := range map[string]func(c *Client, req *http.Request) (*http.Response, error){
+		"Get": func(c *Client, req *http.Request) (*http.Response, error) {
+			return c.Get(context.Background(), req.URL.String())
+		},
+		"Do": func(c *Client, req *http.Request) (*http.Response, error) {
+			return c.Do(req)
+		},
+	} {
+		c := NewClient(&Config{
+			Name:           "test",
+			MaxElapsedTime: 30 * time.Millisecond,
+			ReadyToTrip:    func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		if _, err := do(c, req); err == nil {
+			t.Fatalf("%s: expected timed out request", name)
+		}
+		if state := c.Breaker(req).(*CircuitBreaker).State(); state != StateOpen {
+			t.Fatalf("%s: expected the client deadline to count as a failure, got %s", name, state)
+		}
+	}
+}
+
+func TestClientResponseOutlivesTimeouts(t *testing.T) {
+	var hits atomic.Int32
+	server := newCountingServer(&hits, 0)
+	defer server.Close()
+
+	c := NewClient(&Config{
+		Name:           "test",
+		AttemptTimeout: time.Second,
+		MaxElapsedTime: time.Second,
+	})
+
+	resp, err := c.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "payload:" {
+		t.Fatalf("expected readable body, got %q, %v", body, err)
+	}
+}
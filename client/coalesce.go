@@ -0,0 +1,129 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type CoalesceSettings struct {
+	Methods []string
+	Headers []string
+}
+
+type coalescer struct {
+	methods map[string]bool
+	headers []string
+
+	mutex sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done chan struct{}
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+var credentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+func newCoalescer(st CoalesceSettings) *coalescer {
+	methods := st.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead}
+	}
+
+	g := &coalescer{
+		methods: make(map[string]bool, len(methods)),
+		headers: make([]string, 0, len(credentialHeaders)+len(st.Headers)),
+		calls:   make(map[string]*coalescedCall),
+	}
+	for _, method := range methods {
+		g.methods[method] = true
+	}
+	for _, header := range append(credentialHeaders, st.Headers...) {
+		if header = http.CanonicalHeaderKey(header); !slices.Contains(g.headers, header) {
+			g.headers = append(g.headers, header)
+		}
+	}
+
+	return g
+}
+
+func (g *coalescer) key(req *http.Request) string {
+	var key strings.Builder
+	key.WriteString(req.Method)
+	key.WriteString(" ")
+	key.WriteString(req.URL.String())
+
+	for _, header := range g.headers {
+		key.WriteString("\n")
+		key.WriteString(header)
+		key.WriteString(": ")
+		key.WriteString(strings.Join(req.Header.Values(header), ", "))
+	}
+	return key.String()
+}
+
+func (c *Client) coalesce(req *http.Request, do func(req *http.Request) (*http.Response, error)) (*http.Response, error) {
+	g := c.coalescer
+	if g == nil || !g.methods[req.Method] || (req.Body != nil && req.Body != http.NoBody) {
+		return do(req)
+	}
+
+	key := g.key(req)
+
+	g.mutex.Lock()
+	call, ok := g.calls[key]
+	if !ok {
+		call = &coalescedCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go g.run(key, call, req.WithContext(context.WithoutCancel(req.Context())), do)
+	}
+	g.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.response()
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
+
+func (g *coalescer) run(key string, call *coalescedCall, req *http.Request, do func(req *http.Request) (*http.Response, error)) {
+	defer func() {
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		close(call.done)
+	}()
+
+	resp, err := do(req)
+	if err != nil {
+		call.err = err
+		return
+	}
+
+	if resp != nil && resp.Body != nil {
+		call.body, call.err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	call.resp = resp
+}
+
+func (call *coalescedCall) response() (*http.Response, error) {
+	if call.err != nil || call.resp == nil {
+		return nil, call.err
+	}
+
+	resp := *call.resp
+	resp.Header = call.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(call.body))
+	resp.ContentLength = int64(len(call.body))
+	return &resp, nil
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCountingServer(hits *atomic.Int32, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(delay)
+		io.WriteString(w, "payload:"+r.Header.Get("Accept-Language"))
+	}))
+}
+
+func TestClientCoalescesIdenticalGets(t *testing.T) {
+	var hits atomic.Int32
+	server := newCountingServer(&hits, 50*time.Millisecond)
+	defer server.Close()
+
+	c := NewClient(&Config{
+		Name:     "test",
+		Coalesce: &CoalesceSettings{Headers: []string{"accept-language"}},
+	})
+
+	bodies := make([]string, 20)
+	var wg sync.WaitGroup
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			resp, err := c.Get(context.Background(), server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			bodies[i] = string(body)
+		}(i)
+	}
+	wg.Wait()
+
+	if hits.Load() != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", hits.Load())
+	}
+	for i, body := range bodies {
+		if body != "payload:" {
+			t.Fatalf("expected waiter %d to read the full body, got %q", i, body)
+		}
+	}
+
+	for _, language := range []string{"en", "id"} {
+		wg.Add(1)
+		go func(language string) {
+			defer wg.Done()
+
+			resp, err := c.Get(context.Background(), server.URL, WithHeaders(map[string]string{"Accept-Language": language}))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}(language)
+	}
+	wg.Wait()
+
+	if hits.Load() != 3 {
+		t.Fatalf("expected selected headers to split the calls, got %d upstream calls", hits.Load())
+	}
+}
+
+func TestCoalescedWaiterHonoursContext(t *testing.T) {
+	var hits atomic.Int32
+	server := newCountingServer(&hits, 100*time.Millisecond)
+	defer server.Close()
+
+	c := NewClient(&Config{Name: "test", Coalesce: &CoalesceSettings{}})
+
+	done := make(chan error)
+	go func() {
+		resp, err := c.Get(context.Background(), server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	for hits.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if _, err := c.Do(req); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("expected the shared call to complete, got %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", hits.Load())
+	}
+}
+
+func TestClientDoesNotCoalesceAcrossCredentials(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "secret-of:"+r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	c := NewClient(&Config{Name: "test", Coalesce: &CoalesceSettings{}})
+
+	users := []string{"alice", "bob"}
+	bodies := make([]string, len(users))
+	var wg sync.WaitGroup
+	for i, user := range users {
+		wg.Add(1)
+		go func(i int, user string) {
+			defer wg.Done()
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			req.Header.Set("Authorization", user)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			bodies[i] = string(body)
+		}(i, user)
+	}
+	wg.Wait()
+
+	for i, user := range users {
+		if bodies[i] != "secret-of:"+user {
+			t.Fatalf("expected %s to get their own response, got %q", user, bodies[i])
+		}
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("expected 2 upstream calls, got %d", hits.Load())
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EventType int
+
+const (
+	EventSuccess EventType = iota
+	EventFailure
+	EventCallNotPermitted
+	EventIgnoredError
+	EventStateTransition
+	EventReset
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSuccess:
+		return "success"
+	case EventFailure:
+		return "failure"
+	case EventCallNotPermitted:
+		return "call-not-permitted"
+	case EventIgnoredError:
+		return "ignored-error"
+	case EventStateTransition:
+		return "state-transition"
+	case EventReset:
+		return "reset"
+	default:
+		return fmt.Sprintf("unknown event: %d", t)
+	}
+}
+
+type Event struct {
+	Type     EventType
+	Name     string
+	Time     time.Time
+	Duration time.Duration
+	From     State
+	To       State
+	Err      error
+}
+
+const defaultEventBuffer = 64
+
+type eventBus struct {
+	mutex       sync.RWMutex
+	subscribers map[chan Event]struct{}
+	count       atomic.Int32
+	dropped     atomic.Uint64
+}
+
+func (b *eventBus) subscribe(buffer int) (<-chan Event, func()) {
+	if buffer <= 0 {
+		buffer = defaultEventBuffer
+	}
+
+	ch := make(chan Event, buffer)
+
+	b.mutex.Lock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan Event]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+	b.count.Add(1)
+	b.mutex.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			delete(b.subscribers, ch)
+			b.count.Add(-1)
+			close(ch)
+			b.mutex.Unlock()
+		})
+	}
+}
+
+func (b *eventBus) active() bool {
+	return b.count.Load() > 0
+}
+
+func (b *eventBus) publish(event Event) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCircuitBreakerEvents(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name:        "events",
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+	})
+
+	events, unsubscribe := cb.Subscribe(16)
+
+	succeed(cb)
+	ctx, cancel := context.WithCancel(context.Background())
+	ExecuteContext(ctx, cb, func(ctx context.Context) (int, error) {
+		cancel()
+		return 0, ctx.Err()
+	})
+	fail(cb)
+	succeed(cb)
+	cb.Reset()
+
+	want := []EventType{
+		EventSuccess,
+		EventIgnoredError,
+		EventFailure,
+		EventStateTransition,
+		EventCallNotPermitted,
+		EventStateTransition,
+		EventReset,
+	}
+	for i, typ := range want {
+		event := <-events
+		if event.Type != typ {
+			t.Fatalf("event %d: expected %s, got %s", i, typ, event.Type)
+		}
+		if event.Name != "events" || event.Time.IsZero() {
+			t.Fatalf("event %d: expected name and time to be set, got %+v", i, event)
+		}
+		if event.Type == EventStateTransition && i == 3 && (event.From != StateClosed || event.To != StateOpen) {
+			t.Fatalf("expected transition from closed to open, got %s to %s", event.From, event.To)
+		}
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Fatalf("expected channel to be closed after unsubscribe")
+	}
+}
+
+func TestCircuitBreakerEventsDoNotBlock(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{Name: "events"})
+
+	_, unsubscribe := cb.Subscribe(1)
+	defer unsubscribe()
+
+	for i := 0; i < 10; i++ {
+		succeed(cb)
+	}
+
+	if got := cb.DroppedEvents(); got != 9 {
+		t.Fatalf("expected 9 dropped events, got %d", got)
+	}
+}
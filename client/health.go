@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dyaksa/barbarian"
+)
+
+var errNoProbeRequest = errors.New("health probe has no request")
+var errProbeKeyMismatch = errors.New("health probe template belongs to another breaker key")
+
+type HealthProbe struct {
+	Template       *http.Request
+	NewRequest     func(key string) (*http.Request, error)
+	Interval       time.Duration
+	Timeout        time.Duration
+	IsHealthy      func(resp *http.Response, err error) bool
+	CloseOnHealthy bool
+}
+
+const defaultHealthProbeInterval = time.Duration(5) * time.Second
+
+func defaultIsHealthy(resp *http.Response, err error) bool {
+	return err == nil && resp.StatusCode < http.StatusInternalServerError
+}
+
+func (cb *CircuitBreaker) recoverFromOpen(state State) bool {
+	cb.lock()
+	defer cb.unlock()
+
+	now := time.Now()
+	if current, _ := cb.currentState(now); current != StateOpen {
+		return false
+	}
+
+	cb.setState(state, now)
+	return true
+}
+
+func (c *Client) runHealthProbe(probe HealthProbe) {
+	defer c.wg.Done()
+
+	if probe.Interval <= 0 {
+		probe.Interval = defaultHealthProbeInterval
+	}
+	if probe.Timeout <= 0 {
+		probe.Timeout = probe.Interval
+	}
+	if probe.IsHealthy == nil {
+		probe.IsHealthy = defaultIsHealthy
+	}
+
+	ticker := time.NewTicker(probe.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.breakers.each(func(key string, breaker barbarian.CircuitBreaker) {
+				if cb, ok := breaker.(*CircuitBreaker); ok && cb.State() == StateOpen {
+					c.probeBreaker(probe, key, cb)
+				}
+			})
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Client) probeBreaker(probe HealthProbe, key string, cb *CircuitBreaker) {
+	ctx, cancel := context.WithTimeout(context.Background(), probe.Timeout)
+	defer cancel()
+
+	req, err := c.newProbeRequest(ctx, probe, key)
+	if err != nil {
+		return
+	}
+
+	resp, err := c.httpClient.Do(req)
+	healthy := probe.IsHealthy(resp, err)
+	closeResponse(resp)
+
+	if !healthy {
+		return
+	}
+
+	if probe.CloseOnHealthy {
+		cb.recoverFromOpen(StateClosed)
+	} else {
+		cb.recoverFromOpen(StateHalfOpen)
+	}
+}
+
+func (c *Client) newProbeRequest(ctx context.Context, probe HealthProbe, key string) (*http.Request, error) {
+	if probe.NewRequest != nil {
+		req, err := probe.NewRequest(key)
+		if err != nil {
+			return nil, err
+		}
+		return req.WithContext(ctx), nil
+	}
+
+	if probe.Template == nil {
+		return nil, errNoProbeRequest
+	}
+	if c.breakerKey(probe.Template) != key {
+		return nil, errProbeKeyMismatch
+	}
+	return probe.Template.Clone(ctx), nil
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthProbeRecoversOpenBreaker(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	probe, _ := http.NewRequest(http.MethodGet, server.URL+"/health", nil)
+	c := NewClient(&Config{
+		Name:        "test",
+		Timeout:     time.Hour,
+		Classifier:  StatusClassifier(500),
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		HealthProbe: &HealthProbe{
+			Template: probe,
+			Interval: 10 * time.Millisecond,
+		},
+	})
+	defer c.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	c.Do(req)
+
+	cb := c.Breaker(req).(*CircuitBreaker)
+	time.Sleep(50 * time.Millisecond)
+	if state := cb.State(); state != StateOpen {
+		t.Fatalf("expected breaker to stay open while unhealthy, got %s", state)
+	}
+
+	status.Store(http.StatusOK)
+	deadline := time.Now().Add(time.Second)
+	for cb.State() == StateOpen && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if state := cb.State(); state != StateHalfOpen {
+		t.Fatalf("expected healthy probe to move breaker to half-open, got %s", state)
+	}
+	if counts := cb.Counts(); counts.Requests != 0 {
+		t.Fatalf("expected probes not to be counted, got %d requests", counts.Requests)
+	}
+}
+
+func TestRecoverFromOpenOnlyMovesOpenBreakers(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Timeout:     time.Hour,
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+	})
+
+	if cb.recoverFromOpen(StateClosed) {
+		t.Fatal("expected closed breaker not to be recovered")
+	}
+
+	fail(cb)
+	if !cb.recoverFromOpen(StateClosed) {
+		t.Fatal("expected open breaker to be recovered")
+	}
+	if state := cb.State(); state != StateClosed {
+		t.Fatalf("expected closed, got %s", state)
+	}
+
+	fail(cb)
+	cb.ForceOpen()
+	if cb.recoverFromOpen(StateHalfOpen) {
+		t.Fatal("expected forced-open breaker not to be recovered")
+	}
+}
+
+func TestHealthProbeTemplateOnlyProbesItsKey(t *testing.T) {
+	healthy := newTestServer(http.StatusOK)
+	defer healthy.Close()
+	down := newTestServer(http.StatusInternalServerError)
+	defer down.Close()
+
+	probe, _ := http.NewRequest(http.MethodGet, healthy.URL+"/health", nil)
+	c := NewClient(&Config{
+		Name:           "test",
+		Timeout:        time.Hour,
+		BreakerKeyFunc: KeyByHost,
+		ReadyToTrip:    func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		HealthProbe: &HealthProbe{
+			Template:       probe,
+			Interval:       10 * time.Millisecond,
+			CloseOnHealthy: true,
+		},
+	})
+	defer c.Close()
+
+	healthyReq, _ := http.NewRequest(http.MethodGet, healthy.URL, nil)
+	downReq, _ := http.NewRequest(http.MethodGet, down.URL, nil)
+	healthyBreaker := c.Breaker(healthyReq).(*CircuitBreaker)
+	downBreaker := c.Breaker(downReq).(*CircuitBreaker)
+	fail(healthyBreaker)
+	fail(downBreaker)
+
+	deadline := time.Now().Add(time.Second)
+	for healthyBreaker.State() == StateOpen && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if state := healthyBreaker.State(); state != StateClosed {
+		t.Fatalf("expected the probed host to close, got %s", state)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if state := downBreaker.State(); state != StateOpen {
+		t.Fatalf("expected the other host to stay open, got %s", state)
+	}
+}
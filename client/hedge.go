@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type HedgePolicy struct {
+	Delay        time.Duration
+	Percentile   float64
+	MaxAttempts  int
+	MaxExtraLoad float64
+	Methods      []string
+}
+
+type hedger struct {
+	delay       time.Duration
+	percentile  float64
+	maxAttempts int
+	ratio       float64
+	methods     map[string]bool
+
+	mutex    sync.Mutex
+	current  LatencyHistogram
+	previous LatencyHistogram
+	tokens   float64
+}
+
+func newHedger(policy HedgePolicy) *hedger {
+	h := new(hedger)
+
+	h.delay = policy.Delay
+	h.percentile = min(policy.Percentile, 100)
+
+	if policy.MaxAttempts < 2 {
+		h.maxAttempts = defaultHedgeMaxAttempts
+	} else {
+		h.maxAttempts = policy.MaxAttempts
+	}
+
+	if policy.MaxExtraLoad <= 0 {
+		h.ratio = defaultHedgeMaxExtraLoad / 100
+	} else {
+		h.ratio = policy.MaxExtraLoad / 100
+	}
+
+	methods := policy.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	}
+	h.methods = make(map[string]bool, len(methods))
+	for _, method := range methods {
+		h.methods[method] = true
+	}
+
+	return h
+}
+
+const defaultHedgeMaxAttempts = 2
+const defaultHedgeMaxExtraLoad = float64(10)
+const hedgeLatencyWindow = 1000
+const hedgeMinimumSamples = 20
+const hedgeMaxTokens = float64(10)
+
+func (h *hedger) hedgeable(req *http.Request) bool {
+	return h.methods[req.Method] && (req.Body == nil || req.Body == http.NoBody)
+}
+
+func (h *hedger) nextDelay() (time.Duration, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.tokens = min(h.tokens+h.ratio, hedgeMaxTokens)
+
+	if h.percentile > 0 {
+		if h.current.Count >= hedgeMinimumSamples {
+			return h.current.Percentile(h.percentile), true
+		}
+		if h.previous.Count >= hedgeMinimumSamples {
+			return h.previous.Percentile(h.percentile), true
+		}
+	}
+	return h.delay, h.delay > 0
+}
+
+func (h *hedger) takeToken() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.tokens < 1 {
+		return false
+	}
+	h.tokens--
+	return true
+}
+
+func (h *hedger) record(latency time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.current.record(latency)
+	if h.current.Count >= hedgeLatencyWindow {
+		h.previous = h.current
+		h.current = LatencyHistogram{}
+	}
+}
+
+type hedgeResult struct {
+	index int
+	resp  *http.Response
+	err   error
+}
+
+func (c *Client) hedge(req *http.Request, attempt func(req *http.Request) (*http.Response, error)) (*http.Response, error) {
+	h := c.hedger
+	if h == nil || !h.hedgeable(req) {
+		return attempt(req)
+	}
+
+	delay, ok := h.nextDelay()
+	if !ok {
+		start := time.Now()
+		resp, err := attempt(req)
+		if err == nil {
+			h.record(time.Since(start))
+		}
+		return resp, err
+	}
+
+	results := make(chan hedgeResult, h.maxAttempts)
+	var cancels []context.CancelFunc
+	launch := func(release func()) {
+		ctx, cancel := context.WithCancel(req.Context())
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+
+		go func() {
+			defer release()
+
+			req := req.Clone(ctx)
+			if index > 0 {
+				if err := c.waitRateLimit(req); err != nil {
+					results <- hedgeResult{index: index, err: Ignore(err)}
+					return
+				}
+			}
+
+			start := time.Now()
+			resp, err := attempt(req)
+			if err == nil {
+				h.record(time.Since(start))
+			}
+			results <- hedgeResult{index: index, resp: resp, err: err}
+		}()
+	}
+
+	launch(func() {})
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var lastError error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if len(cancels) < h.maxAttempts && h.takeToken() {
+				if release, ok := c.tryAcquireBulkhead(req); ok {
+					launch(release)
+					pending++
+					timer.Reset(delay)
+				}
+			}
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				cancels[result.index]()
+				closeResponse(result.resp)
+				if _, ignored := unwrapIgnored(result.err); lastError == nil || !ignored {
+					lastError = result.err
+				}
+				continue
+			}
+
+			for i, cancel := range cancels {
+				if i != result.index {
+					cancel()
+				}
+			}
+			go drainHedges(results, pending)
+
+			return withCancelOnClose(result.resp, cancels[result.index]), nil
+		}
+	}
+
+	return nil, lastError
+}
+
+func drainHedges(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		result := <-results
+		closeResponse(result.resp)
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.cancel)
+	return err
+}
+
+func withCancelOnClose(resp *http.Response, cancel context.CancelFunc) *http.Response {
+	if resp == nil || resp.Body == nil {
+		cancel()
+		return resp
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp
+}
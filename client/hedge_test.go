@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newSlowFirstServer(hits *atomic.Int32, slow time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			select {
+			case <-time.After(slow):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		io.WriteString(w, "ok")
+	}))
+}
+
+func TestClientHedgesSlowRequest(t *testing.T) {
+	var hits atomic.Int32
+	server := newSlowFirstServer(&hits, time.Second)
+	defer server.Close()
+
+	c := NewClient(&Config{
+		Name:  "test",
+		Hedge: &HedgePolicy{Delay: 20 * time.Millisecond, MaxExtraLoad: 100},
+	})
+
+	start := time.Now()
+	resp, err := c.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "ok" {
+		t.Fatalf("expected hedged body, got %q, %v", body, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected hedged attempt to win, took %s", elapsed)
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("expected 2 attempts, got %d", hits.Load())
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	cb := c.Breaker(req).(*CircuitBreaker)
+	deadline := time.Now().Add(time.Second)
+	for cb.Counts().Requests != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if counts := cb.Counts(); counts.Requests != 1 || counts.TotalSuccesses != 1 || counts.TotalFailures != 0 {
+		t.Fatalf("expected cancelled loser to be ignored, got %+v", counts)
+	}
+}
+
+func TestClientDoesNotHedgeUnsafeMethods(t *testing.T) {
+	var hits atomic.Int32
+	server := newSlowFirstServer(&hits, 50*time.Millisecond)
+	defer server.Close()
+
+	c := NewClient(&Config{
+		Name:  "test",
+		Hedge: &HedgePolicy{Delay: 5 * time.Millisecond, MaxExtraLoad: 100},
+	})
+
+	resp, err := c.Post(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if hits.Load() != 1 {
+		t.Fatalf("expected POST not to be hedged, got %d attempts", hits.Load())
+	}
+}
+
+func TestHedgerCapsExtraLoad(t *testing.T) {
+	h := newHedger(HedgePolicy{Delay: time.Millisecond, MaxExtraLoad: 50})
+
+	var hedges int
+	for i := 0; i < 10; i++ {
+		if _, ok := h.nextDelay(); !ok {
+			t.Fatal("expected a fixed delay")
+		}
+		if h.takeToken() {
+			hedges++
+		}
+	}
+	if hedges != 5 {
+		t.Fatalf("expected 5 hedges for 10 requests, got %d", hedges)
+	}
+}
+
+func TestHedgerPercentileDelay(t *testing.T) {
+	h := newHedger(HedgePolicy{Percentile: 90})
+
+	if _, ok := h.nextDelay(); ok {
+		t.Fatal("expected no hedging before enough samples")
+	}
+
+	for i := 0; i < hedgeMinimumSamples; i++ {
+		h.record(10 * time.Millisecond)
+	}
+	delay, ok := h.nextDelay()
+	if !ok || delay <= 5*time.Millisecond || delay > 10*time.Millisecond {
+		t.Fatalf("expected delay from the observed p90, got %s, %v", delay, ok)
+	}
+}
+
+func TestClientHedgesEachAttempt(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(40 * time.Millisecond)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	c := newFailingClient(&Config{
+		RetryCount:  2,
+		Hedge:       &HedgePolicy{Delay: 10 * time.Millisecond, MaxExtraLoad: 100},
+		RetryBudget: &RetryBudgetSettings{},
+	}, 0)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("expected failing request")
+	}
+
+	if hits.Load() > 6 {
+		t.Fatalf("expected at most 2 attempts per try, got %d upstream calls", hits.Load())
+	}
+
+	var requests uint64
+	budget := c.budgetFor(req)
+	for _, bucket := range budget.buckets {
+		requests += bucket.requests
+	}
+	if requests != 1 {
+		t.Fatalf("expected the retry budget to see 1 request, got %d", requests)
+	}
+}
+
+func TestClientHedgeNeedsBulkheadSlot(t *testing.T) {
+	var hits atomic.Int32
+	server := newSlowFirstServer(&hits, 50*time.Millisecond)
+	defer server.Close()
+
+	c := NewClient(&Config{
+		Name:     "test",
+		Hedge:    &HedgePolicy{Delay: 5 * time.Millisecond, MaxExtraLoad: 100},
+		Bulkhead: &BulkheadSettings{MaxConcurrentCalls: 1},
+	})
+
+	resp, err := c.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if hits.Load() != 1 {
+		t.Fatalf("expected no hedge without a free bulkhead slot, got %d attempts", hits.Load())
+	}
+}
@@ -0,0 +1,98 @@
+package client
+
+import "time"
+
+var latencyBounds = [...]time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	20 * time.Second,
+	30 * time.Second,
+	60 * time.Second,
+}
+
+func LatencyBucketBounds() []time.Duration {
+	return latencyBounds[:]
+}
+
+type LatencyHistogram struct {
+	Buckets [len(latencyBounds) + 1]uint64 `json:"buckets"`
+	Count   uint64                         `json:"count"`
+	Sum     time.Duration                  `json:"sum"`
+	Max     time.Duration                  `json:"max"`
+}
+
+func (h *LatencyHistogram) record(latency time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && latency > latencyBounds[i] {
+		i++
+	}
+
+	h.Buckets[i]++
+	h.Count++
+	h.Sum += latency
+	h.Max = max(h.Max, latency)
+}
+
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+func (h LatencyHistogram) Percentile(p float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := p / 100 * float64(h.Count)
+	var seen float64
+	for i, count := range h.Buckets {
+		if count == 0 {
+			continue
+		}
+
+		if seen+float64(count) >= rank {
+			var lower time.Duration
+			if i > 0 {
+				lower = latencyBounds[i-1]
+			}
+
+			upper := h.Max
+			if i < len(latencyBounds) {
+				upper = min(latencyBounds[i], h.Max)
+			}
+
+			fraction := (rank - seen) / float64(count)
+			return lower + time.Duration(fraction*float64(upper-lower))
+		}
+		seen += float64(count)
+	}
+	return h.Max
+}
+
+func (h LatencyHistogram) P50() time.Duration {
+	return h.Percentile(50)
+}
+
+func (h LatencyHistogram) P95() time.Duration {
+	return h.Percentile(95)
+}
+
+func (h LatencyHistogram) P99() time.Duration {
+	return h.Percentile(99)
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogramPercentiles(t *testing.T) {
+	var h LatencyHistogram
+	for i := 0; i < 90; i++ {
+		h.record(3 * time.Millisecond)
+	}
+	for i := 0; i < 9; i++ {
+		h.record(150 * time.Millisecond)
+	}
+	h.record(3 * time.Second)
+
+	if h.Count != 100 || h.Max != 3*time.Second {
+		t.Fatalf("unexpected histogram: %+v", h)
+	}
+	if p50 := h.P50(); p50 <= 2*time.Millisecond || p50 > 5*time.Millisecond {
+		t.Fatalf("expected p50 within (2ms, 5ms], got %v", p50)
+	}
+	if p95 := h.P95(); p95 <= 100*time.Millisecond || p95 > 200*time.Millisecond {
+		t.Fatalf("expected p95 within (100ms, 200ms], got %v", p95)
+	}
+	if p99 := h.P99(); p99 <= 100*time.Millisecond || p99 > 200*time.Millisecond {
+		t.Fatalf("expected p99 within (100ms, 200ms], got %v", p99)
+	}
+	if p100 := h.Percentile(100); p100 != 3*time.Second {
+		t.Fatalf("expected p100 to be the maximum, got %v", p100)
+	}
+}
+
+func TestCircuitBreakerTripsOnLatency(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Name: "latency",
+		ReadyToTrip: func(counts Counts) bool {
+			return counts.Latency.Count >= 2 && counts.Latency.P50() > 5*time.Millisecond
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		cb.Execute(func() (interface{}, error) {
+			time.Sleep(10 * time.Millisecond)
+			return nil, errTest
+		})
+	}
+
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open state, got %s", cb.State())
+	}
+}
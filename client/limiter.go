@@ -0,0 +1,248 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrLimitExceeded = errors.New("concurrency limit exceeded")
+
+type LimitAlgorithm int
+
+const (
+	LimitAIMD LimitAlgorithm = iota
+	LimitVegas
+	LimitGradient
+)
+
+func (a LimitAlgorithm) String() string {
+	switch a {
+	case LimitAIMD:
+		return "aimd"
+	case LimitVegas:
+		return "vegas"
+	case LimitGradient:
+		return "gradient"
+	default:
+		return fmt.Sprintf("unknown limit algorithm: %d", a)
+	}
+}
+
+type ConcurrencyLimitSettings struct {
+	Algorithm    LimitAlgorithm
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+
+	BackoffRatio float64
+	Timeout      time.Duration
+
+	Smoothing float64
+	Tolerance float64
+}
+
+type ConcurrencyLimiter struct {
+	algorithm LimitAlgorithm
+	minLimit  float64
+	maxLimit  float64
+
+	backoffRatio float64
+	timeout      time.Duration
+
+	smoothing float64
+	tolerance float64
+
+	mutex      sync.Mutex
+	limit      float64
+	inFlight   int
+	rejections uint64
+	minRTT     time.Duration
+	longRTT    float64
+}
+
+func NewConcurrencyLimiter(st ConcurrencyLimitSettings) *ConcurrencyLimiter {
+	l := new(ConcurrencyLimiter)
+
+	l.algorithm = st.Algorithm
+
+	if st.MinLimit <= 0 {
+		l.minLimit = defaultMinLimit
+	} else {
+		l.minLimit = float64(st.MinLimit)
+	}
+
+	if st.MaxLimit <= 0 {
+		l.maxLimit = defaultMaxLimit
+	} else {
+		l.maxLimit = float64(max(st.MaxLimit, st.MinLimit))
+	}
+
+	if st.InitialLimit <= 0 {
+		l.limit = defaultInitialLimit
+	} else {
+		l.limit = float64(st.InitialLimit)
+	}
+	l.limit = min(max(l.limit, l.minLimit), l.maxLimit)
+
+	if st.BackoffRatio <= 0 || st.BackoffRatio >= 1 {
+		l.backoffRatio = defaultBackoffRatio
+	} else {
+		l.backoffRatio = st.BackoffRatio
+	}
+	l.timeout = st.Timeout
+
+	if st.Smoothing <= 0 || st.Smoothing > 1 {
+		l.smoothing = defaultSmoothing
+	} else {
+		l.smoothing = st.Smoothing
+	}
+
+	if st.Tolerance < 1 {
+		l.tolerance = defaultTolerance
+	} else {
+		l.tolerance = st.Tolerance
+	}
+
+	return l
+}
+
+const defaultInitialLimit = float64(20)
+const defaultMinLimit = float64(1)
+const defaultMaxLimit = float64(1000)
+const defaultBackoffRatio = float64(0.9)
+const defaultSmoothing = float64(0.2)
+const defaultTolerance = float64(1.5)
+
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return int(l.limit)
+}
+
+func (l *ConcurrencyLimiter) InFlight() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.inFlight
+}
+
+func (l *ConcurrencyLimiter) Rejections() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.rejections
+}
+
+func (l *ConcurrencyLimiter) Acquire() (done func(dropped bool), err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.inFlight >= int(l.limit) {
+		l.rejections++
+		return nil, ErrLimitExceeded
+	}
+
+	l.inFlight++
+	inFlight := l.inFlight
+	start := time.Now()
+
+	var once sync.Once
+	return func(dropped bool) {
+		once.Do(func() {
+			l.onSample(time.Since(start), inFlight, dropped)
+		})
+	}, nil
+}
+
+func (l *ConcurrencyLimiter) onSample(rtt time.Duration, inFlight int, dropped bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.inFlight--
+
+	switch l.algorithm {
+	case LimitVegas:
+		l.vegas(rtt, inFlight, dropped)
+	case LimitGradient:
+		l.gradient(rtt, dropped)
+	default:
+		l.aimd(rtt, inFlight, dropped)
+	}
+
+	l.limit = min(max(l.limit, l.minLimit), l.maxLimit)
+}
+
+func (l *ConcurrencyLimiter) aimd(rtt time.Duration, inFlight int, dropped bool) {
+	if dropped || (l.timeout > 0 && rtt > l.timeout) {
+		l.limit *= l.backoffRatio
+	} else if float64(inFlight)*2 >= l.limit {
+		l.limit++
+	}
+}
+
+func (l *ConcurrencyLimiter) vegas(rtt time.Duration, inFlight int, dropped bool) {
+	if rtt <= 0 {
+		return
+	}
+	if l.minRTT == 0 || rtt < l.minRTT {
+		l.minRTT = rtt
+	}
+
+	step := max(1, math.Log10(l.limit))
+	if dropped {
+		l.limit -= step
+		return
+	}
+
+	queue := math.Ceil(l.limit * (1 - float64(l.minRTT)/float64(rtt)))
+	switch {
+	case queue >= 6*step:
+		l.limit -= step
+	case float64(inFlight)*2 < l.limit:
+		return
+	case queue <= step:
+		l.limit += 6 * step
+	case queue < 3*step:
+		l.limit += step
+	}
+}
+
+func (l *ConcurrencyLimiter) gradient(rtt time.Duration, dropped bool) {
+	if rtt <= 0 {
+		return
+	}
+
+	if l.longRTT == 0 {
+		l.longRTT = float64(rtt)
+	} else {
+		l.longRTT = l.longRTT*(1-l.smoothing) + float64(rtt)*l.smoothing
+	}
+
+	gradient := max(0.5, min(1, l.tolerance*l.longRTT/float64(rtt)))
+	if dropped {
+		gradient = 0.5
+	}
+
+	limit := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = l.limit*(1-l.smoothing) + limit*l.smoothing
+}
+
+func (c *Client) ConcurrencyLimiter(req *http.Request) *ConcurrencyLimiter {
+	if c.limiters == nil {
+		return nil
+	}
+	return c.limiters.get(c.breakerKey(req))
+}
+
+func (c *Client) acquireLimit(req *http.Request) (done func(dropped bool), err error) {
+	limiter := c.ConcurrencyLimiter(req)
+	if limiter == nil {
+		return func(bool) {}, nil
+	}
+	return limiter.Acquire()
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiterRejectsAboveLimit(t *testing.T) {
+	l := NewConcurrencyLimiter(ConcurrencyLimitSettings{InitialLimit: 2})
+
+	first, _ := l.Acquire()
+	second, _ := l.Acquire()
+	if _, err := l.Acquire(); err != ErrLimitExceeded {
+		t.Fatalf("expected %v, got %v", ErrLimitExceeded, err)
+	}
+	if l.InFlight() != 2 || l.Rejections() != 1 {
+		t.Fatalf("expected 2 in flight and 1 rejection, got %d and %d", l.InFlight(), l.Rejections())
+	}
+
+	first(false)
+	first(false)
+	second(false)
+	if l.InFlight() != 0 {
+		t.Fatalf("expected no calls in flight, got %d", l.InFlight())
+	}
+}
+
+func TestConcurrencyLimiterAIMD(t *testing.T) {
+	l := NewConcurrencyLimiter(ConcurrencyLimitSettings{
+		Algorithm:    LimitAIMD,
+		InitialLimit: 10,
+		BackoffRatio: 0.5,
+		Timeout:      100 * time.Millisecond,
+	})
+
+	l.inFlight++
+	l.onSample(time.Millisecond, 10, false)
+	if l.Limit() != 11 {
+		t.Fatalf("expected additive increase to 11, got %d", l.Limit())
+	}
+
+	l.inFlight++
+	l.onSample(time.Millisecond, 1, false)
+	if l.Limit() != 11 {
+		t.Fatalf("expected an under-used limit to stay at 11, got %d", l.Limit())
+	}
+
+	l.inFlight++
+	l.onSample(time.Millisecond, 10, true)
+	if l.Limit() != 5 {
+		t.Fatalf("expected multiplicative decrease to 5, got %d", l.Limit())
+	}
+
+	l.inFlight++
+	l.onSample(time.Second, 5, false)
+	if l.Limit() != 2 {
+		t.Fatalf("expected a timeout to decrease the limit to 2, got %d", l.Limit())
+	}
+}
+
+func TestConcurrencyLimiterVegas(t *testing.T) {
+	l := NewConcurrencyLimiter(ConcurrencyLimitSettings{Algorithm: LimitVegas, InitialLimit: 20})
+
+	l.inFlight++
+	l.onSample(10*time.Millisecond, 20, false)
+	if l.Limit() <= 20 {
+		t.Fatalf("expected limit to grow without queueing, got %d", l.Limit())
+	}
+
+	grown := l.Limit()
+	l.inFlight++
+	l.onSample(100*time.Millisecond, grown, false)
+	if l.Limit() >= grown {
+		t.Fatalf("expected limit to shrink when latency grows, got %d", l.Limit())
+	}
+}
+
+func TestConcurrencyLimiterGradient(t *testing.T) {
+	l := NewConcurrencyLimiter(ConcurrencyLimitSettings{Algorithm: LimitGradient, InitialLimit: 100})
+
+	for i := 0; i < 10; i++ {
+		l.inFlight++
+		l.onSample(10*time.Millisecond, 100, false)
+	}
+	steady := l.Limit()
+	if steady <= 100 {
+		t.Fatalf("expected limit to grow at steady latency, got %d", steady)
+	}
+
+	for i := 0; i < 10; i++ {
+		l.inFlight++
+		l.onSample(200*time.Millisecond, 100, false)
+	}
+	if l.Limit() >= steady {
+		t.Fatalf("expected limit to shrink when latency spikes, got %d", l.Limit())
+	}
+}
+
+func TestClientConcurrencyLimit(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	c := NewClient(&Config{
+		Name:             "test",
+		ConcurrencyLimit: &ConcurrencyLimitSettings{InitialLimit: 1, MaxLimit: 1},
+	})
+
+	go c.Get(context.Background(), server.URL)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	limiter := c.ConcurrencyLimiter(req)
+	for limiter.InFlight() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := c.Do(req); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected %v, got %v", ErrLimitExceeded, err)
+	}
+	if limiter.Rejections() != 1 {
+		t.Fatalf("expected 1 rejection, got %d", limiter.Rejections())
+	}
+	if counts := c.Breaker(req).(*CircuitBreaker).Counts(); counts.TotalFailures != 0 {
+		t.Fatalf("expected rejection not to be counted by the breaker, got %+v", counts)
+	}
+}
+
+func TestClientLimitedRetryKeepsLastError(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	c := newFailingClient(&Config{
+		RetryCount:       2,
+		ConcurrencyLimit: &ConcurrencyLimitSettings{InitialLimit: 1, MaxLimit: 1},
+	}, 100*time.Millisecond)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	limiter := c.ConcurrencyLimiter(req)
+
+	result := make(chan error)
+	go func() {
+		_, err := c.Do(req)
+		result <- err
+	}()
+
+	for hits.Load() != 1 || limiter.InFlight() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	done, err := limiter.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done(false)
+
+	err = <-result
+	if err == nil || errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected the upstream failure, got %v", err)
+	}
+	if hits.Load() != 1 || limiter.Rejections() != 1 {
+		t.Fatalf("expected the retry to be rejected, got %d attempts and %d rejections", hits.Load(), limiter.Rejections())
+	}
+}
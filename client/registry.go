@@ -0,0 +1,124 @@
+package client
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type KeyFunc func(req *http.Request) string
+
+func KeyByHost(req *http.Request) string {
+	return req.URL.Host
+}
+
+func KeyByMethodAndPath(req *http.Request) string {
+	return req.Method + " " + req.URL.Host + req.URL.Path
+}
+
+func KeyByRoute(templates ...string) KeyFunc {
+	routes := make([][]string, len(templates))
+	for i, template := range templates {
+		routes[i] = splitPath(template)
+	}
+
+	return func(req *http.Request) string {
+		segments := splitPath(req.URL.Path)
+		for i, route := range routes {
+			if matchRoute(route, segments) {
+				return req.Method + " " + req.URL.Host + templates[i]
+			}
+		}
+		return KeyByMethodAndPath(req)
+	}
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func matchRoute(route, segments []string) bool {
+	if len(route) != len(segments) {
+		return false
+	}
+
+	for i, segment := range route {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			continue
+		}
+		if segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func defaultKeyFunc(req *http.Request) string {
+	return ""
+}
+
+type registryEntry[T any] struct {
+	value    T
+	lastUsed time.Time
+}
+
+type registry[T any] struct {
+	create      func(key string) T
+	idleTimeout time.Duration
+
+	mutex     sync.Mutex
+	entries   map[string]*registryEntry[T]
+	nextSweep time.Time
+}
+
+func newRegistry[T any](create func(key string) T, idleTimeout time.Duration) *registry[T] {
+	return &registry[T]{
+		create:      create,
+		idleTimeout: idleTimeout,
+		entries:     make(map[string]*registryEntry[T]),
+	}
+}
+
+func (r *registry[T]) get(key string) T {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	r.evictIdle(now)
+
+	entry, ok := r.entries[key]
+	if !ok {
+		entry = &registryEntry[T]{value: r.create(key)}
+		r.entries[key] = entry
+	}
+	entry.lastUsed = now
+
+	return entry.value
+}
+
+func (r *registry[T]) each(f func(key string, value T)) {
+	r.mutex.Lock()
+	entries := make(map[string]T, len(r.entries))
+	for key, entry := range r.entries {
+		entries[key] = entry.value
+	}
+	r.mutex.Unlock()
+
+	for key, value := range entries {
+		f(key, value)
+	}
+}
+
+func (r *registry[T]) evictIdle(now time.Time) {
+	if r.idleTimeout <= 0 || now.Before(r.nextSweep) {
+		return
+	}
+
+	for key, entry := range r.entries {
+		if now.Sub(entry.lastUsed) > r.idleTimeout {
+			delete(r.entries, key)
+		}
+	}
+	r.nextSweep = now.Add(r.idleTimeout)
+}
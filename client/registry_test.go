@@ -0,0 +1,52 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestKeyByRoute(t *testing.T) {
+	key := KeyByRoute("/users/{id}", "/users/{id}/orders")
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://api.local/users/42", "GET api.local/users/{id}"},
+		{"http://api.local/users/42/orders", "GET api.local/users/{id}/orders"},
+		{"http://api.local/health", "GET api.local/health"},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		if got := key(req); got != tt.want {
+			t.Errorf("KeyByRoute(%s) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRegistryCreatesLazilyAndEvictsIdle(t *testing.T) {
+	created := 0
+	r := newRegistry(func(key string) string {
+		created++
+		return key
+	}, 10*time.Millisecond)
+
+	r.get("a")
+	r.get("a")
+	if created != 1 {
+		t.Fatalf("expected 1 entry to be created, got %d", created)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	r.get("b")
+	if _, ok := r.entries["a"]; ok {
+		t.Fatalf("expected idle entry to be evicted")
+	}
+
+	r.get("a")
+	if created != 3 {
+		t.Fatalf("expected evicted entry to be recreated, got %d creations", created)
+	}
+}
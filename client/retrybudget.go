@@ -0,0 +1,113 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+type RetryBudgetSettings struct {
+	RetryPercent        float64
+	MinRetriesPerSecond float64
+	Window              time.Duration
+	PerHost             bool
+}
+
+type retryBudgetBucket struct {
+	start    time.Time
+	requests uint64
+	retries  uint64
+}
+
+type retryBudget struct {
+	percent    float64
+	minRetries float64
+	width      time.Duration
+
+	mutex   sync.Mutex
+	buckets []retryBudgetBucket
+}
+
+func newRetryBudget(st RetryBudgetSettings) *retryBudget {
+	b := new(retryBudget)
+
+	if st.RetryPercent <= 0 {
+		b.percent = defaultRetryPercent
+	} else {
+		b.percent = st.RetryPercent
+	}
+
+	window := st.Window
+	if window <= 0 {
+		window = defaultRetryBudgetWindow
+	}
+	b.width = window / retryBudgetBuckets
+	b.buckets = make([]retryBudgetBucket, retryBudgetBuckets)
+
+	if st.MinRetriesPerSecond < 0 {
+		b.minRetries = 0
+	} else if st.MinRetriesPerSecond == 0 {
+		b.minRetries = defaultMinRetriesPerSecond * window.Seconds()
+	} else {
+		b.minRetries = st.MinRetriesPerSecond * window.Seconds()
+	}
+
+	return b
+}
+
+const defaultRetryPercent = float64(20)
+const defaultMinRetriesPerSecond = float64(10)
+const defaultRetryBudgetWindow = time.Duration(10) * time.Second
+const retryBudgetBuckets = 10
+
+func (b *retryBudget) bucket(now time.Time) *retryBudgetBucket {
+	start := now.Truncate(b.width)
+	bucket := &b.buckets[(start.UnixNano()/int64(b.width))%int64(len(b.buckets))]
+	if !bucket.start.Equal(start) {
+		*bucket = retryBudgetBucket{start: start}
+	}
+	return bucket
+}
+
+func (b *retryBudget) onRequest(now time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.bucket(now).requests++
+}
+
+func (b *retryBudget) allowRetry(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	current := b.bucket(now)
+
+	var requests, retries uint64
+	for _, bucket := range b.buckets {
+		if now.Sub(bucket.start) < b.width*time.Duration(len(b.buckets)) {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+
+	if float64(retries+1) > float64(requests)*b.percent/100+b.minRetries {
+		return false
+	}
+
+	current.retries++
+	return true
+}
+
+func (c *Client) budgetFor(req *http.Request) *retryBudget {
+	if c.retryBudgets == nil {
+		return nil
+	}
+
+	if c.retryBudgetPerHost {
+		return c.retryBudgets.get(req.URL.Host)
+	}
+	return c.retryBudgets.get("")
+}
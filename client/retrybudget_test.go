@@ -0,0 +1,94 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type recordingLogger struct {
+	mutex  sync.Mutex
+	errors []error
+}
+
+func (l *recordingLogger) Type() string {
+	return "logger"
+}
+
+func (l *recordingLogger) OnRequestStart(req *http.Request) {}
+
+func (l *recordingLogger) OnRequestEnd(req *http.Request, res *http.Response) {}
+
+func (l *recordingLogger) OnRequestError(req *http.Request, err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.errors = append(l.errors, err)
+}
+
+func TestRetryBudgetLimitsRetries(t *testing.T) {
+	b := newRetryBudget(RetryBudgetSettings{RetryPercent: 20, MinRetriesPerSecond: -1, Window: 10 * time.Second})
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		b.onRequest(now)
+	}
+	if !b.allowRetry(now) || !b.allowRetry(now) {
+		t.Fatal("expected 20% of the requests to be retried")
+	}
+	if b.allowRetry(now) {
+		t.Fatal("expected the budget to be exhausted")
+	}
+
+	later := now.Add(11 * time.Second)
+	b.onRequest(later)
+	if b.allowRetry(later) {
+		t.Fatal("expected the budget to follow the rolling window")
+	}
+}
+
+func TestRetryBudgetMinimumRetries(t *testing.T) {
+	b := newRetryBudget(RetryBudgetSettings{RetryPercent: 20, MinRetriesPerSecond: 0.5, Window: 4 * time.Second})
+	now := time.Now()
+
+	b.onRequest(now)
+	if !b.allowRetry(now) || !b.allowRetry(now) {
+		t.Fatal("expected the floor to allow 2 retries over the window")
+	}
+	if b.allowRetry(now) {
+		t.Fatal("expected the budget to be exhausted")
+	}
+}
+
+func TestClientRetryBudgetRefusesRetries(t *testing.T) {
+	var hits atomic.Int32
+	server := newCountingServer(&hits, 0)
+	defer server.Close()
+
+	c := NewClient(&Config{
+		Name:        "test",
+		Classifier:  StatusClassifier(200),
+		RetryCount:  4,
+		RetryBudget: &RetryBudgetSettings{RetryPercent: 50, MinRetriesPerSecond: -1},
+	})
+	logger := &recordingLogger{}
+	c.AddPlugin(logger)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("expected failing request")
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("expected the retry to be refused, got %d attempts", hits.Load())
+	}
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	if len(logger.errors) == 0 || !errors.Is(logger.errors[len(logger.errors)-1], ErrRetryBudgetExhausted) {
+		t.Fatalf("expected %v to be reported, got %v", ErrRetryBudgetExhausted, logger.errors)
+	}
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+type ErrorClass int
+
+const (
+	ErrorConnectionRefused ErrorClass = iota
+	ErrorConnectionReset
+	ErrorTimeout
+	ErrorDNS
+)
+
+func (e ErrorClass) String() string {
+	switch e {
+	case ErrorConnectionRefused:
+		return "connection-refused"
+	case ErrorConnectionReset:
+		return "connection-reset"
+	case ErrorTimeout:
+		return "timeout"
+	case ErrorDNS:
+		return "dns"
+	default:
+		return fmt.Sprintf("unknown error class: %d", e)
+	}
+}
+
+func (e ErrorClass) matches(err error) bool {
+	switch e {
+	case ErrorConnectionRefused:
+		return errors.Is(err, syscall.ECONNREFUSED)
+	case ErrorConnectionReset:
+		return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	case ErrorTimeout:
+		return isTimeout(err)
+	case ErrorDNS:
+		var dnsErr *net.DNSError
+		return errors.As(err, &dnsErr)
+	default:
+		return false
+	}
+}
+
+type RetryPolicy struct {
+	StatusCodes []int
+	Methods     []string
+	Errors      []ErrorClass
+	Retryable   func(req *http.Request, resp *http.Response, err error) bool
+}
+
+type retryPolicy struct {
+	statusCodes map[int]bool
+	methods     map[string]bool
+	errors      []ErrorClass
+	retryable   func(req *http.Request, resp *http.Response, err error) bool
+}
+
+func newRetryPolicy(policy RetryPolicy) *retryPolicy {
+	p := &retryPolicy{
+		errors:    policy.Errors,
+		retryable: policy.Retryable,
+	}
+
+	statusCodes := policy.StatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	p.statusCodes = make(map[int]bool, len(statusCodes))
+	for _, code := range statusCodes {
+		p.statusCodes[code] = true
+	}
+
+	methods := policy.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete}
+	}
+	p.methods = make(map[string]bool, len(methods))
+	for _, method := range methods {
+		p.methods[method] = true
+	}
+
+	if len(p.errors) == 0 {
+		p.errors = []ErrorClass{ErrorConnectionRefused, ErrorConnectionReset, ErrorTimeout, ErrorDNS}
+	}
+
+	return p
+}
+
+func (p *retryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if !p.methods[req.Method] {
+		return false
+	}
+
+	if p.retryable != nil {
+		return p.retryable(req, resp, err)
+	}
+
+	if resp != nil {
+		return p.statusCodes[resp.StatusCode]
+	}
+
+	for _, class := range p.errors {
+		if class.matches(err) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	p := newRetryPolicy(RetryPolicy{})
+	get, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	post, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+
+	refused := errors.Wrap(&url.Error{Op: "Get", URL: "http://example.com", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, "failed to execute request")
+	dns := errors.Wrap(&url.Error{Op: "Get", URL: "http://example.com", Err: &net.DNSError{Err: "no such host", Name: "example.com"}}, "failed to execute request")
+	certificate := errors.Wrap(&url.Error{Op: "Get", URL: "http://example.com", Err: x509.UnknownAuthorityError{}}, "failed to execute request")
+
+	cases := []struct {
+		req  *http.Request
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{get, &http.Response{StatusCode: http.StatusServiceUnavailable}, errTest, true},
+		{get, &http.Response{StatusCode: http.StatusInternalServerError}, errTest, false},
+		{post, &http.Response{StatusCode: http.StatusServiceUnavailable}, errTest, false},
+		{get, nil, refused, true},
+		{get, nil, dns, true},
+		{get, nil, context.DeadlineExceeded, true},
+		{get, nil, certificate, false},
+	}
+
+	for i, c := range cases {
+		if got := p.shouldRetry(c.req, c.resp, c.err); got != c.want {
+			t.Fatalf("case %d: expected %v, got %v", i, c.want, got)
+		}
+	}
+
+	p = newRetryPolicy(RetryPolicy{
+		Errors: []ErrorClass{ErrorDNS},
+		Retryable: func(req *http.Request, resp *http.Response, err error) bool {
+			return resp != nil && resp.Header.Get("Retry-After") != ""
+		},
+	})
+	resp := &http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{"Retry-After": {"1"}}}
+	if !p.shouldRetry(get, resp, errTest) {
+		t.Fatal("expected the predicate to decide")
+	}
+	if p.shouldRetry(post, resp, errTest) {
+		t.Fatal("expected methods to apply before the predicate")
+	}
+}
+
+func TestClientRetryPolicy(t *testing.T) {
+	var hits atomic.Int32
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	c := newFailingClient(&Config{RetryCount: 3, RetryPolicy: &RetryPolicy{}}, 0)
+
+	for _, tc := range []struct {
+		method string
+		status int
+		want   int32
+	}{
+		{http.MethodGet, http.StatusServiceUnavailable, 3},
+		{http.MethodPost, http.StatusServiceUnavailable, 1},
+		{http.MethodGet, http.StatusInternalServerError, 1},
+	} {
+		hits.Store(0)
+		status = tc.status
+
+		req, _ := http.NewRequest(tc.method, server.URL, nil)
+		if _, err := c.Do(req); err == nil {
+			t.Fatal("expected failing request")
+		}
+		if hits.Load() != tc.want {
+			t.Fatalf("%s %d: expected %d attempts, got %d", tc.method, tc.status, tc.want, hits.Load())
+		}
+	}
+}
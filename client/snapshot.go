@@ -0,0 +1,154 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/dyaksa/barbarian"
+)
+
+type Snapshot struct {
+	Name       string        `json:"name"`
+	State      State         `json:"state"`
+	Generation uint64        `json:"generation"`
+	Counts     Counts        `json:"counts"`
+	ExpiresIn  time.Duration `json:"expires_in"`
+	TakenAt    time.Time     `json:"taken_at"`
+}
+
+type snapshotter interface {
+	Snapshot() Snapshot
+	Restore(snapshot Snapshot) error
+}
+
+var _ snapshotter = (*CircuitBreaker)(nil)
+
+func (cb *CircuitBreaker) Snapshot() Snapshot {
+	cb.lock()
+	defer cb.unlock()
+
+	now := time.Now()
+	state, generation := cb.currentState(now)
+
+	var expiresIn time.Duration
+	if !cb.expiry.IsZero() {
+		expiresIn = max(cb.expiry.Sub(now), 0)
+	}
+
+	return Snapshot{
+		Name:       cb.name,
+		State:      state,
+		Generation: generation,
+		Counts:     cb.counts,
+		ExpiresIn:  expiresIn,
+		TakenAt:    now,
+	}
+}
+
+func (cb *CircuitBreaker) Restore(snapshot Snapshot) error {
+	if snapshot.State < StateClosed || snapshot.State > StateDisabled {
+		return ErrUnknownState
+	}
+
+	cb.lock()
+	defer cb.unlock()
+
+	now := time.Now()
+	expiresIn := snapshot.ExpiresIn
+	if !snapshot.TakenAt.IsZero() && snapshot.TakenAt.Before(now) {
+		expiresIn -= now.Sub(snapshot.TakenAt)
+	}
+
+	prev := cb.state
+	since := cb.changedAt
+	cb.state = snapshot.State
+	cb.changedAt = now
+	cb.generation = max(cb.generation+1, snapshot.Generation)
+	cb.halfOpenFailures = 0
+	cb.counts = snapshot.Counts
+	if cb.state == StateHalfOpen {
+		cb.counts.Requests = 0
+		cb.counts.ConsecutiveSuccesses = 0
+		cb.counts.ConsecutiveFailures = 0
+	}
+
+	var zero time.Time
+	switch {
+	case snapshot.ExpiresIn > 0:
+		cb.expiry = now.Add(max(expiresIn, 0))
+	case cb.state == StateOpen:
+		cb.expiry = now
+	case (cb.state == StateClosed || cb.state == StateForcedClosed) && cb.interval > 0:
+		cb.expiry = now.Add(cb.interval)
+	default:
+		cb.expiry = zero
+	}
+
+	cb.resetWindows()
+	cb.saveState(now, true)
+
+	if prev != cb.state {
+		cb.notifyStateChange(prev, cb.state, now, since)
+	}
+	return nil
+}
+
+func (c *Client) Snapshot() map[string]Snapshot {
+	snapshots := make(map[string]Snapshot)
+	c.breakers.each(func(key string, breaker barbarian.CircuitBreaker) {
+		if s, ok := breaker.(snapshotter); ok {
+			snapshots[key] = s.Snapshot()
+		}
+	})
+	return snapshots
+}
+
+func (c *Client) Restore(snapshots map[string]Snapshot) error {
+	var errs []error
+	for key, snapshot := range snapshots {
+		if s, ok := c.breakers.get(key).(snapshotter); ok {
+			errs = append(errs, s.Restore(snapshot))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Client) saveSnapshot(path string) error {
+	b, err := json.Marshal(c.Snapshot())
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+func (c *Client) loadSnapshot(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var snapshots map[string]Snapshot
+	if err := json.Unmarshal(b, &snapshots); err != nil {
+		return err
+	}
+	return c.Restore(snapshots)
+}
+
+func (c *Client) persistSnapshots(path string, interval time.Duration) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = c.saveSnapshot(path)
+		case <-c.done:
+			_ = c.saveSnapshot(path)
+			return
+		}
+	}
+}
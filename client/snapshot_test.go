@@ -0,0 +1,115 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerSnapshotRestore(t *testing.T) {
+	settings := Settings{
+		Name:        "snapshot",
+		Timeout:     time.Minute,
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+	}
+
+	cb := NewCircuitBreaker(settings)
+	fail(cb)
+
+	b, err := json.Marshal(cb.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"state":"open"`) {
+		t.Fatalf("expected state to be encoded by name, got %s", b)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewCircuitBreaker(settings)
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if restored.State() != StateOpen {
+		t.Fatalf("expected restored breaker to be open, got %s", restored.State())
+	}
+
+	snapshot.TakenAt = snapshot.TakenAt.Add(-2 * time.Minute)
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if restored.State() != StateHalfOpen {
+		t.Fatalf("expected expired open snapshot to resume half-open, got %s", restored.State())
+	}
+
+	if err := restored.Restore(Snapshot{State: State(42)}); err != ErrUnknownState {
+		t.Fatalf("expected %v, got %v", ErrUnknownState, err)
+	}
+}
+
+func TestCircuitBreakerRestoreHalfOpenDuringProbe(t *testing.T) {
+	settings := Settings{
+		Name:        "probe",
+		MaxRequests: 1,
+		Timeout:     time.Millisecond,
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+	}
+
+	cb := NewCircuitBreaker(settings)
+	fail(cb)
+	time.Sleep(5 * time.Millisecond)
+
+	done, err := cb.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := cb.Snapshot()
+	done(true)
+	if snapshot.State != StateHalfOpen || snapshot.Counts.Requests != 1 {
+		t.Fatalf("expected a half-open snapshot with a probe in flight, got %+v", snapshot)
+	}
+
+	restored := NewCircuitBreaker(settings)
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := succeed(restored); err != nil {
+		t.Fatalf("expected the restored breaker to accept a probe, got %v", err)
+	}
+	if restored.State() != StateClosed {
+		t.Fatalf("expected a successful probe to close the breaker, got %s", restored.State())
+	}
+}
+
+func TestClientPersistsSnapshots(t *testing.T) {
+	server := newTestServer(http.StatusInternalServerError)
+	defer server.Close()
+
+	config := &Config{
+		Name:                         "test",
+		Timeout:                      time.Minute,
+		ConsiderServerErrorAsFailure: true,
+		ServerErrorThreshold:         500,
+		ReadyToTrip:                  func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		SnapshotPath:                 filepath.Join(t.TempDir(), "breakers.json"),
+		SnapshotInterval:             time.Hour,
+	}
+
+	c := NewClient(config)
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	c.Do(req)
+	c.Close()
+
+	restarted := NewClient(config)
+	defer restarted.Close()
+
+	if state := restarted.Breaker(req).(*CircuitBreaker).State(); state != StateOpen {
+		t.Fatalf("expected restarted client to resume open, got %s", state)
+	}
+}
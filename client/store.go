@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrStateNotFound = errors.New("circuit breaker state not found")
+
+type StoredState struct {
+	State      State     `json:"state"`
+	Generation uint64    `json:"generation"`
+	Counts     Counts    `json:"counts"`
+	Expiry     time.Time `json:"expiry"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+type StateStore interface {
+	Load(name string) (StoredState, error)
+	Save(name string, state StoredState) error
+}
+
+type memoryStateStore struct {
+	mutex  sync.RWMutex
+	states map[string]StoredState
+}
+
+func NewMemoryStateStore() StateStore {
+	return &memoryStateStore{
+		states: make(map[string]StoredState),
+	}
+}
+
+func (s *memoryStateStore) Load(name string) (StoredState, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	state, ok := s.states[name]
+	if !ok {
+		return StoredState{}, ErrStateNotFound
+	}
+	return state, nil
+}
+
+func (s *memoryStateStore) Save(name string, state StoredState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.states[name] = state
+	return nil
+}
+
+type fileStateStore struct {
+	dir string
+}
+
+func NewFileStateStore(dir string) (StateStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &fileStateStore{
+		dir: dir,
+	}, nil
+}
+
+func (s *fileStateStore) path(name string) string {
+	return filepath.Join(s.dir, url.PathEscape(name)+".json")
+}
+
+func (s *fileStateStore) Load(name string) (StoredState, error) {
+	var state StoredState
+
+	b, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, ErrStateNotFound
+	} else if err != nil {
+		return state, err
+	}
+
+	err = json.Unmarshal(b, &state)
+	return state, err
+}
+
+func (s *fileStateStore) Save(name string, state StoredState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path(name), b)
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+type RemoteStateClient interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+type remoteStateStore struct {
+	client  RemoteStateClient
+	prefix  string
+	timeout time.Duration
+}
+
+func NewRemoteStateStore(client RemoteStateClient, prefix string, timeout time.Duration) StateStore {
+	if timeout <= 0 {
+		timeout = defaultRemoteStateTimeout
+	}
+
+	return &remoteStateStore{
+		client:  client,
+		prefix:  prefix,
+		timeout: timeout,
+	}
+}
+
+const defaultRemoteStateTimeout = time.Duration(100) * time.Millisecond
+
+func (s *remoteStateStore) Load(name string) (StoredState, error) {
+	var state StoredState
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	b, err := s.client.Get(ctx, s.prefix+name)
+	if err != nil {
+		return state, err
+	}
+
+	err = json.Unmarshal(b, &state)
+	return state, err
+}
+
+func (s *remoteStateStore) Save(name string, state StoredState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	return s.client.Set(ctx, s.prefix+name, b)
+}
+
+type FakeRemoteStateClient struct {
+	mutex  sync.Mutex
+	values map[string][]byte
+	err    error
+}
+
+var _ RemoteStateClient = (*FakeRemoteStateClient)(nil)
+
+func NewFakeRemoteStateClient() *FakeRemoteStateClient {
+	return &FakeRemoteStateClient{
+		values: make(map[string][]byte),
+	}
+}
+
+func (f *FakeRemoteStateClient) Get(ctx context.Context, key string) ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	value, ok := f.values[key]
+	if !ok {
+		return nil, ErrStateNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (f *FakeRemoteStateClient) Set(ctx context.Context, key string, value []byte) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+
+	f.values[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (f *FakeRemoteStateClient) SetError(err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.err = err
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testSharedState(t *testing.T, store StateStore) {
+	t.Helper()
+
+	settings := Settings{
+		Name:              "shared",
+		ReadyToTrip:       func(counts Counts) bool { return counts.ConsecutiveFailures >= 2 },
+		StateStore:        store,
+		StateSyncInterval: time.Nanosecond,
+	}
+	a := NewCircuitBreaker(settings)
+	b := NewCircuitBreaker(settings)
+
+	fail(a)
+	fail(a)
+	if a.State() != StateOpen {
+		t.Fatalf("expected first breaker to be open, got %s", a.State())
+	}
+
+	if b.State() != StateOpen {
+		t.Fatalf("expected second breaker to adopt open state, got %s", b.State())
+	}
+	if err := succeed(b); !errors.Is(err, ErrOpenState) {
+		t.Fatalf("expected %v, got %v", ErrOpenState, err)
+	}
+}
+
+func TestMemoryStateStoreSharesState(t *testing.T) {
+	testSharedState(t, NewMemoryStateStore())
+}
+
+func TestFileStateStoreSharesState(t *testing.T) {
+	store, err := NewFileStateStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSharedState(t, store)
+}
+
+func TestRemoteStateStoreSharesState(t *testing.T) {
+	testSharedState(t, NewRemoteStateStore(NewFakeRemoteStateClient(), "breaker:", 0))
+}
+
+func TestRemoteStateStoreErrorKeepsLocalState(t *testing.T) {
+	remote := NewFakeRemoteStateClient()
+	remote.SetError(errors.New("connection refused"))
+
+	cb := NewCircuitBreaker(Settings{
+		Name:              "unavailable",
+		ReadyToTrip:       func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		StateStore:        NewRemoteStateStore(remote, "breaker:", 0),
+		StateSyncInterval: time.Nanosecond,
+	})
+
+	fail(cb)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected local state to be open, got %s", cb.State())
+	}
+}
+
+type blockingStateStore struct {
+	StateStore
+	loading chan struct{}
+	unblock chan struct{}
+}
+
+func (s *blockingStateStore) Load(name string) (StoredState, error) {
+	s.loading <- struct{}{}
+	<-s.unblock
+	return s.StateStore.Load(name)
+}
+
+func TestSlowStateStoreDoesNotBlockBreaker(t *testing.T) {
+	store := &blockingStateStore{
+		StateStore: NewMemoryStateStore(),
+		loading:    make(chan struct{}),
+		unblock:    make(chan struct{}),
+	}
+	cb := NewCircuitBreaker(Settings{Name: "slow", StateStore: store})
+
+	go cb.State()
+	<-store.loading
+	defer close(store.unblock)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		succeed(cb)
+		cb.Counts()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected breaker calls not to wait for the store")
+	}
+	if counts := cb.Counts(); counts.TotalSuccesses != 1 {
+		t.Fatalf("expected 1 success, got %+v", counts)
+	}
+}
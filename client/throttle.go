@@ -0,0 +1,152 @@
+package client
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/dyaksa/barbarian"
+)
+
+var ErrThrottled = errors.New("request throttled")
+
+type ThrottleSettings struct {
+	Name         string
+	K            float64
+	Window       time.Duration
+	Buckets      int
+	IsSuccessful func(err error) bool
+}
+
+type AdaptiveThrottle struct {
+	name         string
+	k            float64
+	isSuccessful func(err error) bool
+
+	mutex  sync.Mutex
+	window *rollingWindow
+}
+
+var _ barbarian.CircuitBreaker = (*AdaptiveThrottle)(nil)
+
+func NewAdaptiveThrottle(st ThrottleSettings) *AdaptiveThrottle {
+	t := new(AdaptiveThrottle)
+
+	t.name = st.Name
+
+	if st.K <= 0 {
+		t.k = defaultThrottleK
+	} else {
+		t.k = st.K
+	}
+
+	if st.IsSuccessful == nil {
+		t.isSuccessful = defaultIsSuccessful
+	} else {
+		t.isSuccessful = st.IsSuccessful
+	}
+
+	window := st.Window
+	if window <= 0 {
+		window = defaultThrottleWindow
+	}
+
+	buckets := st.Buckets
+	if buckets <= 0 {
+		buckets = defaultThrottleBuckets
+	}
+
+	t.window = newRollingWindow(buckets, window/time.Duration(buckets))
+
+	return t
+}
+
+const defaultThrottleK = float64(2)
+const defaultThrottleWindow = time.Duration(2) * time.Minute
+const defaultThrottleBuckets = 12
+
+func (t *AdaptiveThrottle) Name() string {
+	return t.name
+}
+
+func (t *AdaptiveThrottle) RejectionProbability() float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.rejectionProbability(time.Now())
+}
+
+func (t *AdaptiveThrottle) Execute(req func() (interface{}, error)) (interface{}, error) {
+	return t.execute(req, nil)
+}
+
+func (t *AdaptiveThrottle) execute(req func() (interface{}, error), ignore func(err error) bool) (interface{}, error) {
+	if err := t.beforeRequest(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer func() {
+		e := recover()
+		if e != nil {
+			t.afterRequest(callResult{latency: time.Since(start)})
+			panic(e)
+		}
+	}()
+
+	result, err := req()
+	err, ignored := unwrapIgnored(err)
+	t.afterRequest(callResult{
+		success: t.isSuccessful(err),
+		timeout: isTimeout(err),
+		ignored: ignored || (ignore != nil && ignore(err)),
+		latency: time.Since(start),
+	})
+	return result, err
+}
+
+func (t *AdaptiveThrottle) Allow() (done func(success bool), err error) {
+	if err := t.beforeRequest(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() {
+			t.afterRequest(callResult{success: success, latency: time.Since(start)})
+		})
+	}, nil
+}
+
+func (t *AdaptiveThrottle) beforeRequest() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	if rand.Float64() < t.rejectionProbability(now) {
+		t.window.reject(now)
+		return ErrThrottled
+	}
+	return nil
+}
+
+func (t *AdaptiveThrottle) afterRequest(res callResult) {
+	if res.ignored {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.window.record(time.Now(), res)
+}
+
+func (t *AdaptiveThrottle) rejectionProbability(now time.Time) float64 {
+	counts := t.window.aggregate(now)
+	requests := float64(counts.Requests() + counts.Rejections)
+	accepts := float64(counts.Successes)
+
+	return max(0, (requests-t.k*accepts)/(requests+1))
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/dyaksa/barbarian"
+)
+
+func TestAdaptiveThrottleRejectsWhenBackendFails(t *testing.T) {
+	throttle := NewAdaptiveThrottle(ThrottleSettings{Name: "throttle"})
+
+	for i := 0; i < 10; i++ {
+		throttle.Execute(func() (interface{}, error) { return nil, nil })
+	}
+	if p := throttle.RejectionProbability(); p != 0 {
+		t.Fatalf("expected no rejections while backend accepts, got %v", p)
+	}
+
+	for i := 0; i < 100; i++ {
+		throttle.Execute(func() (interface{}, error) { return nil, errTest })
+	}
+
+	p := throttle.RejectionProbability()
+	if p <= 0.5 {
+		t.Fatalf("expected rejection probability above 0.5, got %v", p)
+	}
+
+	rejected := 0
+	for i := 0; i < 100; i++ {
+		_, err := throttle.Execute(func() (interface{}, error) { return nil, errTest })
+		if errors.Is(err, ErrThrottled) {
+			rejected++
+		}
+	}
+	if rejected == 0 {
+		t.Fatalf("expected some requests to be throttled")
+	}
+}
+
+func TestClientWithAdaptiveThrottle(t *testing.T) {
+	server := newTestServer(http.StatusOK)
+	defer server.Close()
+
+	c := NewClient(&Config{
+		Name: "test",
+		BreakerFactory: func(name string) barbarian.CircuitBreaker {
+			return NewAdaptiveThrottle(ThrottleSettings{Name: name})
+		},
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("expected request to succeed, got %v", err)
+	}
+	resp.Body.Close()
+
+	if _, ok := c.Breaker(req).(*AdaptiveThrottle); !ok {
+		t.Fatalf("expected client to use the adaptive throttle, got %T", c.Breaker(req))
+	}
+}
+
+func TestAdaptiveThrottleAllow(t *testing.T) {
+	throttle := NewAdaptiveThrottle(ThrottleSettings{Name: "throttle"})
+
+	for i := 0; i < 50; i++ {
+		done, err := throttle.Allow()
+		if err == nil {
+			done(false)
+		}
+	}
+
+	if p := throttle.RejectionProbability(); p <= 0.5 {
+		t.Fatalf("expected rejection probability above 0.5, got %v", p)
+	}
+}
@@ -0,0 +1,167 @@
+package client
+
+import "time"
+
+type slidingWindow struct {
+	outcomes  []bool
+	slow      []bool
+	next      uint32
+	calls     uint32
+	failures  uint32
+	slowCalls uint32
+}
+
+func newSlidingWindow(size uint32) *slidingWindow {
+	return &slidingWindow{
+		outcomes: make([]bool, size),
+		slow:     make([]bool, size),
+	}
+}
+
+func (w *slidingWindow) record(failure bool, slow bool) {
+	if w.calls == uint32(len(w.outcomes)) {
+		if w.outcomes[w.next] {
+			w.failures--
+		}
+		if w.slow[w.next] {
+			w.slowCalls--
+		}
+	} else {
+		w.calls++
+	}
+
+	w.outcomes[w.next] = failure
+	if failure {
+		w.failures++
+	}
+
+	w.slow[w.next] = slow
+	if slow {
+		w.slowCalls++
+	}
+
+	w.next = (w.next + 1) % uint32(len(w.outcomes))
+}
+
+func (w *slidingWindow) failureRate() float64 {
+	if w.calls == 0 {
+		return 0
+	}
+	return float64(w.failures) / float64(w.calls) * 100
+}
+
+func (w *slidingWindow) slowCallRate() float64 {
+	if w.calls == 0 {
+		return 0
+	}
+	return float64(w.slowCalls) / float64(w.calls) * 100
+}
+
+func (w *slidingWindow) reset() {
+	for i := range w.outcomes {
+		w.outcomes[i] = false
+		w.slow[i] = false
+	}
+	w.next = 0
+	w.calls = 0
+	w.failures = 0
+	w.slowCalls = 0
+}
+
+type RollingCounts struct {
+	Successes    uint64
+	Failures     uint64
+	Timeouts     uint64
+	Rejections   uint64
+	TotalLatency time.Duration
+}
+
+func (c RollingCounts) Requests() uint64 {
+	return c.Successes + c.Failures + c.Timeouts
+}
+
+func (c RollingCounts) ErrorPercentage() float64 {
+	requests := c.Requests()
+	if requests == 0 {
+		return 0
+	}
+	return float64(c.Failures+c.Timeouts) / float64(requests) * 100
+}
+
+func (c RollingCounts) MeanLatency() time.Duration {
+	requests := c.Requests()
+	if requests == 0 {
+		return 0
+	}
+	return c.TotalLatency / time.Duration(requests)
+}
+
+func (c *RollingCounts) add(o RollingCounts) {
+	c.Successes += o.Successes
+	c.Failures += o.Failures
+	c.Timeouts += o.Timeouts
+	c.Rejections += o.Rejections
+	c.TotalLatency += o.TotalLatency
+}
+
+type rollingBucket struct {
+	epoch  int64
+	counts RollingCounts
+}
+
+type rollingWindow struct {
+	buckets []rollingBucket
+	width   time.Duration
+}
+
+func newRollingWindow(buckets int, width time.Duration) *rollingWindow {
+	return &rollingWindow{
+		buckets: make([]rollingBucket, buckets),
+		width:   width,
+	}
+}
+
+func (w *rollingWindow) bucket(now time.Time) *RollingCounts {
+	epoch := now.UnixNano() / int64(w.width)
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if b.epoch != epoch {
+		b.epoch = epoch
+		b.counts = RollingCounts{}
+	}
+	return &b.counts
+}
+
+func (w *rollingWindow) record(now time.Time, res callResult) {
+	b := w.bucket(now)
+	switch {
+	case res.success:
+		b.Successes++
+	case res.timeout:
+		b.Timeouts++
+	default:
+		b.Failures++
+	}
+	b.TotalLatency += res.latency
+}
+
+func (w *rollingWindow) reject(now time.Time) {
+	w.bucket(now).Rejections++
+}
+
+func (w *rollingWindow) aggregate(now time.Time) RollingCounts {
+	epoch := now.UnixNano() / int64(w.width)
+
+	var total RollingCounts
+	for _, b := range w.buckets {
+		if b.epoch <= epoch && epoch-b.epoch < int64(len(w.buckets)) {
+			total.add(b.counts)
+		}
+	}
+	return total
+}
+
+func (w *rollingWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = rollingBucket{}
+	}
+}
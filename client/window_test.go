@@ -0,0 +1,52 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowEvictsOldestOutcome(t *testing.T) {
+	w := newSlidingWindow(4)
+
+	w.record(true, false)
+	w.record(true, false)
+	w.record(false, false)
+	w.record(false, false)
+	if got := w.failureRate(); got != 50 {
+		t.Fatalf("expected failure rate 50, got %v", got)
+	}
+
+	w.record(false, false)
+	w.record(false, false)
+	if got := w.failureRate(); got != 0 {
+		t.Fatalf("expected failure rate 0, got %v", got)
+	}
+
+	w.reset()
+	if w.calls != 0 || w.failureRate() != 0 {
+		t.Fatalf("expected empty window after reset")
+	}
+}
+
+func TestRollingWindowAggregatesLiveBuckets(t *testing.T) {
+	w := newRollingWindow(3, time.Second)
+	now := time.Unix(100, 0)
+
+	w.record(now, callResult{success: true, latency: 10 * time.Millisecond})
+	w.record(now.Add(time.Second), callResult{latency: 30 * time.Millisecond})
+	w.record(now.Add(2*time.Second), callResult{timeout: true, latency: 50 * time.Millisecond})
+	w.reject(now.Add(2 * time.Second))
+
+	total := w.aggregate(now.Add(2 * time.Second))
+	if total.Successes != 1 || total.Failures != 1 || total.Timeouts != 1 || total.Rejections != 1 {
+		t.Fatalf("unexpected aggregate: %+v", total)
+	}
+	if got := total.MeanLatency(); got != 30*time.Millisecond {
+		t.Fatalf("expected mean latency 30ms, got %v", got)
+	}
+
+	total = w.aggregate(now.Add(3 * time.Second))
+	if total.Successes != 0 || total.Requests() != 2 {
+		t.Fatalf("expected oldest bucket to expire, got %+v", total)
+	}
+}
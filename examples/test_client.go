@@ -14,9 +14,8 @@ import (
 
 func main() {
 	client := client.NewClient(&client.Config{
-		Name:                         "test",
-		ConsiderServerErrorAsFailure: true,
-		ServerErrorThreshold:         500,
+		Name:       "test",
+		Classifier: client.StatusClassifier(500),
 		ReadyToTrip: func(cunts client.Counts) bool {
 			return cunts.TotalFailures > 2
 		},
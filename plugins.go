@@ -17,6 +17,11 @@ type RetryPlugins interface {
 	NextInterval(retry int) time.Duration
 }
 
+type RateLimiterPlugins interface {
+	Plugin
+	Wait(req *http.Request) error
+}
+
 type Plugin interface {
 	Type() string
 }
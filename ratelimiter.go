@@ -0,0 +1,187 @@
+package barbarian
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+type RateLimitPolicy int
+
+const (
+	RateLimitWait RateLimitPolicy = iota
+	RateLimitReject
+)
+
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type RateLimiterSettings struct {
+	Default RateLimit
+	Limits  map[string]RateLimit
+	Key     func(req *http.Request) string
+	Policy  RateLimitPolicy
+}
+
+type rateLimiter struct {
+	defaultLimit RateLimit
+	limits       map[string]RateLimit
+	key          func(req *http.Request) string
+	policy       RateLimitPolicy
+
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func NewRateLimiter(st RateLimiterSettings) RateLimiterPlugins {
+	r := &rateLimiter{
+		defaultLimit: st.Default,
+		limits:       st.Limits,
+		key:          st.Key,
+		policy:       st.Policy,
+		buckets:      make(map[string]*tokenBucket),
+	}
+
+	if r.key == nil {
+		r.key = keyByHost
+	}
+
+	return r
+}
+
+func keyByHost(req *http.Request) string {
+	return req.URL.Host
+}
+
+func (r *rateLimiter) Type() string {
+	return "ratelimiter"
+}
+
+func (r *rateLimiter) Wait(req *http.Request) error {
+	bucket := r.bucket(r.key(req))
+	if bucket == nil {
+		return nil
+	}
+
+	now := time.Now()
+	if r.policy == RateLimitReject {
+		if !bucket.take(now) {
+			return ErrRateLimited
+		}
+		return nil
+	}
+
+	ctx := req.Context()
+	deadline, hasDeadline := ctx.Deadline()
+	delay, ok := bucket.reserve(now, deadline, hasDeadline)
+	if !ok {
+		return ErrRateLimited
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		bucket.cancel()
+		return ctx.Err()
+	}
+}
+
+func (r *rateLimiter) bucket(key string) *tokenBucket {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if bucket, ok := r.buckets[key]; ok {
+		return bucket
+	}
+
+	limit, ok := r.limits[key]
+	if !ok {
+		limit = r.defaultLimit
+	}
+
+	var bucket *tokenBucket
+	if limit.Rate > 0 {
+		bucket = newTokenBucket(limit)
+	}
+	r.buckets[key] = bucket
+	return bucket
+}
+
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+func (b *tokenBucket) take(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *tokenBucket) reserve(now time.Time, deadline time.Time, hasDeadline bool) (time.Duration, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(now)
+
+	var delay time.Duration
+	if b.tokens < 1 {
+		delay = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	if hasDeadline && now.Add(delay).After(deadline) {
+		return 0, false
+	}
+
+	b.tokens--
+	return delay, true
+}
+
+func (b *tokenBucket) cancel() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}